@@ -0,0 +1,157 @@
+package sparsevector
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// Accumulator sums many SparseVectorUint32 into one without allocating a new
+// vector for each addition, as repeated calls to Add would.
+//
+// Values are scattered into a workspace, and the indices touched are recorded
+// so that only they need to be gathered into the result and cleared
+// afterwards. If the accumulator is given the size of the index space the
+// workspace is a dense slice of that size, which is fastest. Otherwise it is a
+// map.
+//
+// An Accumulator can be reused after calling Sum, which empties it.
+type Accumulator struct {
+	// dense is the workspace when the index space is known
+	dense   []Value
+	present []bool
+	// hashed is the workspace otherwise
+	hashed  map[uint32]Value
+	touched []uint32
+}
+
+// NewAccumulator creates an Accumulator. If size is greater than zero every
+// index added must be less than size, and a dense workspace of that size is
+// used. If size is zero a hashed workspace is used, for any index.
+func NewAccumulator(size int) *Accumulator {
+	if size > 0 {
+		return &Accumulator{
+			dense:   make([]Value, size),
+			present: make([]bool, size),
+		}
+	}
+	return &Accumulator{hashed: make(map[uint32]Value)}
+}
+
+// Add adds a vector into the accumulator
+func (a *Accumulator) Add(sv *SparseVectorUint32) {
+	a.AddScaled(sv, 1)
+}
+
+// AddScaled adds a vector multiplied by a constant into the accumulator. This
+// can be used to build weighted sums, such as centroids.
+func (a *Accumulator) AddScaled(sv *SparseVectorUint32, l Value) {
+	if a.dense != nil {
+		for i, index := range sv.indices {
+			if !a.present[index] {
+				a.present[index] = true
+				a.touched = append(a.touched, index)
+			}
+			a.dense[index] += l * sv.values[i]
+		}
+		return
+	}
+	for i, index := range sv.indices {
+		v, ok := a.hashed[index]
+		if !ok {
+			a.touched = append(a.touched, index)
+		}
+		a.hashed[index] = v + l*sv.values[i]
+	}
+}
+
+// Len returns the number of distinct indices added since the accumulator was
+// created or last summed
+func (a *Accumulator) Len() int { return len(a.touched) }
+
+// Sum returns the sum of the vectors added, and empties the accumulator
+func (a *Accumulator) Sum() *SparseVectorUint32 {
+	sort.Sort(Uint32Index(a.touched))
+	sv := &SparseVectorUint32{
+		indices: make([]uint32, len(a.touched)),
+		values:  make([]Value, len(a.touched)),
+	}
+	copy(sv.indices, a.touched)
+	for i, index := range a.touched {
+		if a.dense != nil {
+			sv.values[i] = a.dense[index]
+			a.dense[index] = 0
+			a.present[index] = false
+		} else {
+			sv.values[i] = a.hashed[index]
+			delete(a.hashed, index)
+		}
+	}
+	a.touched = a.touched[:0]
+	return sv
+}
+
+// SumMerge sums vectors by merging them with a heap, without a workspace.
+// This suits a few vectors spread over a huge index space, where an
+// Accumulator's workspace would be large. Values at the same index are summed
+// in the order of the vectors.
+func SumMerge(vectors ...*SparseVectorUint32) *SparseVectorUint32 {
+	var total int
+	h := make(mergeHeap, 0, len(vectors))
+	for v, sv := range vectors {
+		total += len(sv.indices)
+		if len(sv.indices) > 0 {
+			h = append(h, mergeCursor{sv: sv, vector: v})
+		}
+	}
+	heap.Init(&h)
+
+	out := &SparseVectorUint32{
+		indices: make([]uint32, 0, total),
+		values:  make([]Value, 0, total),
+	}
+	for len(h) > 0 {
+		c := &h[0]
+		index := c.sv.indices[c.pos]
+		value := c.sv.values[c.pos]
+		if l := len(out.indices); l > 0 && out.indices[l-1] == index {
+			out.values[l-1] += value
+		} else {
+			out.indices = append(out.indices, index)
+			out.values = append(out.values, value)
+		}
+		c.pos++
+		if c.pos == len(c.sv.indices) {
+			heap.Pop(&h)
+		} else {
+			heap.Fix(&h, 0)
+		}
+	}
+	return out
+}
+
+// mergeCursor is a position in one of the vectors being merged
+type mergeCursor struct {
+	sv     *SparseVectorUint32
+	pos    int
+	vector int
+}
+
+// mergeHeap orders cursors by their current index, and then by vector
+type mergeHeap []mergeCursor
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	ii, ij := h[i].sv.indices[h[i].pos], h[j].sv.indices[h[j].pos]
+	if ii != ij {
+		return ii < ij
+	}
+	return h[i].vector < h[j].vector
+}
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(mergeCursor)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
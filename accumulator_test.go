@@ -0,0 +1,112 @@
+package sparsevector
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func BenchmarkAccumulatorDense(b *testing.B) {
+	benchmarkAccumulator(b, 20000)
+}
+
+func BenchmarkAccumulatorHashed(b *testing.B) {
+	benchmarkAccumulator(b, 0)
+}
+
+func BenchmarkSumMerge(b *testing.B) {
+	vectors := genAccumulatorTestVectors(rand.New(rand.NewSource(1)), 1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		SumMerge(vectors...)
+	}
+}
+
+func BenchmarkRepeatedAdd(b *testing.B) {
+	vectors := genAccumulatorTestVectors(rand.New(rand.NewSource(1)), 1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var sum Vector = NewSparseVectorUint32(nil, nil)
+		for _, sv := range vectors {
+			sum = sum.Add(sv)
+		}
+	}
+}
+
+func benchmarkAccumulator(b *testing.B, size int) {
+	vectors := genAccumulatorTestVectors(rand.New(rand.NewSource(1)), 1000)
+	a := NewAccumulator(size)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, sv := range vectors {
+			a.Add(sv)
+		}
+		a.Sum()
+	}
+}
+
+func genAccumulatorTestVectors(rnd *rand.Rand, n int) []*SparseVectorUint32 {
+	vectors := make([]*SparseVectorUint32, n)
+	for i := range vectors {
+		vectors[i] = genSpreadSparseVector(rnd, 1+rnd.Intn(100), 20000)
+	}
+	return vectors
+}
+
+func TestAccumulator(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	for i := 0; i < 10; i++ {
+		vectors := genAccumulatorTestVectors(rnd, 1+rnd.Intn(50))
+
+		// Sum with repeated Add
+		exp := NewSparseVectorUint32([]uint32{}, []Value{})
+		for _, sv := range vectors {
+			exp = exp.Add(sv).(*SparseVectorUint32)
+		}
+
+		for _, a := range []*Accumulator{NewAccumulator(20000), NewAccumulator(0)} {
+			// Use each accumulator twice to check it is emptied
+			for rep := 0; rep < 2; rep++ {
+				for _, sv := range vectors {
+					a.Add(sv)
+				}
+				if a.Len() != len(exp.indices) {
+					t.Errorf("Test %d. Len is %d, expected %d", i, a.Len(), len(exp.indices))
+				}
+				sum := a.Sum()
+				if !reflect.DeepEqual(exp.indices, sum.indices) || !reflect.DeepEqual(exp.values, sum.values) {
+					t.Errorf("Test %d. Sum not as expected", i)
+				}
+			}
+		}
+
+		sum := SumMerge(vectors...)
+		if !reflect.DeepEqual(exp.indices, sum.indices) || !reflect.DeepEqual(exp.values, sum.values) {
+			t.Errorf("Test %d. SumMerge not as expected", i)
+		}
+	}
+}
+
+func TestAccumulatorScaled(t *testing.T) {
+	a := NewAccumulator(0)
+	a.AddScaled(NewSparseVectorUint32([]uint32{1, 1 << 31}, []Value{1, 2}), 2)
+	a.AddScaled(NewSparseVectorUint32([]uint32{1, 3}, []Value{1, 2}), -1)
+	if sum := a.Sum(); !reflect.DeepEqual(NewSparseVectorUint32([]uint32{1, 3, 1 << 31}, []Value{1, -2, 4}), sum) {
+		t.Errorf("Sum not as expected. Have %v", sum)
+	}
+	if sum := a.Sum(); len(sum.indices) != 0 {
+		t.Errorf("Accumulator not emptied. Have %v", sum)
+	}
+	if sum := SumMerge(); len(sum.indices) != 0 {
+		t.Errorf("SumMerge of nothing not empty. Have %v", sum)
+	}
+}
@@ -0,0 +1,382 @@
+package sparsevector
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// BitmapVector is a sparse vector where every present value is the same, so
+// only the set of present indices needs to be stored. This is the case for
+// binary data, where every value is 1.
+//
+// The set of indices is stored as a compressed bitmap in the style of Roaring
+// bitmaps. Indices are grouped by their top 16 bits, and the bottom 16 bits of
+// each group are stored in a container. Each container is whichever is
+// smallest of a sorted array, a 65536 bit bitmap, or a list of runs.
+//
+// The dot product of two binary vectors is the size of the intersection of
+// their index sets, and the magnitude is the square root of the number of
+// indices.
+type BitmapVector struct {
+	keys       []uint16
+	containers []bitmapContainer
+	card       int
+	// weight is the value of every present entry
+	weight Value
+}
+
+// bitmapContainer holds the bottom 16 bits of the indices that share a key.
+type bitmapContainer interface {
+	cardinality() int
+	contains(x uint16) bool
+	iter(f func(x uint16))
+}
+
+// Containers with more than this many entries are smaller as a bitmap than as
+// an array
+const bitmapArrayMax = 4096
+
+type arrayContainer []uint16
+
+type bitsetContainer []uint64
+
+// bitmapRun is a run of consecutive values from start to start+length
+// inclusive
+type bitmapRun struct {
+	start  uint16
+	length uint16
+}
+
+type runContainer []bitmapRun
+
+// NewBitmapVector creates a BitmapVector with the value 1 at each of the
+// indices given. The indices need not be sorted, and duplicates are ignored.
+// indices is not modified.
+func NewBitmapVector(indices []uint32) *BitmapVector {
+	sorted := append([]uint32(nil), indices...)
+	sort.Sort(Uint32Index(sorted))
+
+	b := &BitmapVector{weight: 1}
+	for start := 0; start < len(sorted); {
+		key := uint16(sorted[start] >> 16)
+		end := start
+		lows := make([]uint16, 0, 16)
+		for end < len(sorted) && uint16(sorted[end]>>16) == key {
+			low := uint16(sorted[end])
+			if len(lows) == 0 || lows[len(lows)-1] != low {
+				lows = append(lows, low)
+			}
+			end++
+		}
+		b.keys = append(b.keys, key)
+		b.containers = append(b.containers, newBitmapContainer(lows))
+		b.card += len(lows)
+		start = end
+	}
+	debugCheck(b)
+	return b
+}
+
+// newBitmapContainer picks the smallest container for a sorted set of values
+func newBitmapContainer(lows []uint16) bitmapContainer {
+	var nruns int
+	for i := range lows {
+		if i == 0 || lows[i] != lows[i-1]+1 {
+			nruns++
+		}
+	}
+
+	// Sizes in bytes of each container type
+	arraySize := 2 * len(lows)
+	bitsetSize := 8192
+	runSize := 4 * nruns
+
+	if runSize < arraySize && runSize < bitsetSize {
+		runs := make(runContainer, 0, nruns)
+		for i, low := range lows {
+			if i == 0 || low != lows[i-1]+1 {
+				runs = append(runs, bitmapRun{start: low})
+			} else {
+				runs[len(runs)-1].length++
+			}
+		}
+		return runs
+	}
+	if len(lows) <= bitmapArrayMax {
+		return arrayContainer(lows)
+	}
+	bitset := make(bitsetContainer, 1024)
+	for _, low := range lows {
+		bitset[low/64] |= 1 << (low % 64)
+	}
+	return bitset
+}
+
+func (a arrayContainer) cardinality() int { return len(a) }
+
+func (a arrayContainer) contains(x uint16) bool {
+	i := sort.Search(len(a), func(i int) bool { return a[i] >= x })
+	return i < len(a) && a[i] == x
+}
+
+func (a arrayContainer) iter(f func(x uint16)) {
+	for _, x := range a {
+		f(x)
+	}
+}
+
+func (b bitsetContainer) cardinality() int {
+	var card int
+	for _, w := range b {
+		card += bits.OnesCount64(w)
+	}
+	return card
+}
+
+func (b bitsetContainer) contains(x uint16) bool {
+	return b[x/64]&(1<<(x%64)) != 0
+}
+
+func (b bitsetContainer) iter(f func(x uint16)) {
+	for i, w := range b {
+		for w != 0 {
+			t := bits.TrailingZeros64(w)
+			f(uint16(i*64 + t))
+			w &= w - 1
+		}
+	}
+}
+
+// countRange counts the bits set from start to end inclusive
+func (b bitsetContainer) countRange(start, end int) int {
+	firstWord, lastWord := start/64, end/64
+	firstMask := ^uint64(0) << (start % 64)
+	lastMask := ^uint64(0) >> (63 - end%64)
+	if firstWord == lastWord {
+		return bits.OnesCount64(b[firstWord] & firstMask & lastMask)
+	}
+	count := bits.OnesCount64(b[firstWord]&firstMask) + bits.OnesCount64(b[lastWord]&lastMask)
+	for _, w := range b[firstWord+1 : lastWord] {
+		count += bits.OnesCount64(w)
+	}
+	return count
+}
+
+func (r runContainer) cardinality() int {
+	var card int
+	for _, run := range r {
+		card += int(run.length) + 1
+	}
+	return card
+}
+
+func (r runContainer) contains(x uint16) bool {
+	// Find the first run that ends at or after x
+	i := sort.Search(len(r), func(i int) bool { return int(r[i].start)+int(r[i].length) >= int(x) })
+	return i < len(r) && r[i].start <= x
+}
+
+func (r runContainer) iter(f func(x uint16)) {
+	for _, run := range r {
+		for x := int(run.start); x <= int(run.start)+int(run.length); x++ {
+			f(uint16(x))
+		}
+	}
+}
+
+// intersectionCount counts the values present in both containers
+func intersectionCount(c1, c2 bitmapContainer) int {
+	switch c1 := c1.(type) {
+	case arrayContainer:
+		if c2, ok := c2.(arrayContainer); ok {
+			var i1, i2, count int
+			for i1 < len(c1) && i2 < len(c2) {
+				if c1[i1] < c2[i2] {
+					i1++
+				} else if c2[i2] < c1[i1] {
+					i2++
+				} else {
+					count++
+					i1++
+					i2++
+				}
+			}
+			return count
+		}
+		var count int
+		for _, x := range c1 {
+			if c2.contains(x) {
+				count++
+			}
+		}
+		return count
+
+	case bitsetContainer:
+		switch c2 := c2.(type) {
+		case arrayContainer:
+			return intersectionCount(c2, c1)
+		case bitsetContainer:
+			var count int
+			for i, w := range c1 {
+				count += bits.OnesCount64(w & c2[i])
+			}
+			return count
+		case runContainer:
+			var count int
+			for _, run := range c2 {
+				count += c1.countRange(int(run.start), int(run.start)+int(run.length))
+			}
+			return count
+		}
+
+	case runContainer:
+		switch c2 := c2.(type) {
+		case arrayContainer, bitsetContainer:
+			return intersectionCount(c2, c1)
+		case runContainer:
+			var i1, i2, count int
+			for i1 < len(c1) && i2 < len(c2) {
+				s1, e1 := int(c1[i1].start), int(c1[i1].start)+int(c1[i1].length)
+				s2, e2 := int(c2[i2].start), int(c2[i2].start)+int(c2[i2].length)
+				start, end := s1, e1
+				if s2 > start {
+					start = s2
+				}
+				if e2 < end {
+					end = e2
+				}
+				if end >= start {
+					count += end - start + 1
+				}
+				if e1 <= e2 {
+					i1++
+				}
+				if e2 <= e1 {
+					i2++
+				}
+			}
+			return count
+		}
+	}
+	panic("sparsevector: unknown bitmap container")
+}
+
+// Cardinality returns the number of indices present in the vector
+func (b *BitmapVector) Cardinality() int { return b.card }
+
+// Contains returns true if index is present in the vector
+func (b *BitmapVector) Contains(index uint32) bool {
+	key := uint16(index >> 16)
+	i := sort.Search(len(b.keys), func(i int) bool { return b.keys[i] >= key })
+	return i < len(b.keys) && b.keys[i] == key && b.containers[i].contains(uint16(index))
+}
+
+// Iter lets you iterate over the members of the sparse vector in index order
+func (b *BitmapVector) Iter(f func(index uint32, value Value)) {
+	for i, key := range b.keys {
+		high := uint32(key) << 16
+		b.containers[i].iter(func(x uint16) {
+			f(high|uint32(x), b.weight)
+		})
+	}
+}
+
+// ToSparseVector converts the vector to a SparseVectorUint32
+func (b *BitmapVector) ToSparseVector() *SparseVectorUint32 {
+	indices := make([]uint32, 0, b.card)
+	values := make([]Value, 0, b.card)
+	b.Iter(func(index uint32, value Value) {
+		indices = append(indices, index)
+		values = append(values, value)
+	})
+	return &SparseVectorUint32{indices: indices, values: values}
+}
+
+// Mag returns the magnitude of the vector. For a binary vector this is the
+// square root of the number of indices present.
+func (b *BitmapVector) Mag() Value {
+	return Value(math.Abs(float64(b.weight)) * math.Sqrt(float64(b.card)))
+}
+
+// Dot calculates the dot product of this vector and another, which may be a
+// BitmapVector or a SparseVectorUint32. For two binary vectors this is the
+// number of indices they have in common.
+func (b *BitmapVector) Dot(v Vector) Value {
+	switch v := v.(type) {
+	case *BitmapVector:
+		return b.weight * v.weight * Value(b.intersectionCount(v))
+	case *SparseVectorUint32:
+		return b.weight * b.sumPresent(v)
+	}
+	panic("sparsevector: BitmapVector.Dot needs a BitmapVector or SparseVectorUint32")
+}
+
+func (b1 *BitmapVector) intersectionCount(b2 *BitmapVector) int {
+	var i1, i2, count int
+	for i1 < len(b1.keys) && i2 < len(b2.keys) {
+		if b1.keys[i1] < b2.keys[i2] {
+			i1++
+		} else if b2.keys[i2] < b1.keys[i1] {
+			i2++
+		} else {
+			count += intersectionCount(b1.containers[i1], b2.containers[i2])
+			i1++
+			i2++
+		}
+	}
+	return count
+}
+
+// sumPresent adds up the values in sv whose indices are present in b
+func (b *BitmapVector) sumPresent(sv *SparseVectorUint32) Value {
+	var total Value
+	var c int
+	for i, index := range sv.indices {
+		key := uint16(index >> 16)
+		for c < len(b.keys) && b.keys[c] < key {
+			c++
+		}
+		if c == len(b.keys) {
+			break
+		}
+		if b.keys[c] == key && b.containers[c].contains(uint16(index)) {
+			total += sv.values[i]
+		}
+	}
+	return total
+}
+
+// Cos calculates the cosine of the angle between this vector and another,
+// which may be a BitmapVector or a SparseVectorUint32.
+func (b *BitmapVector) Cos(v Vector) Value {
+	return b.Dot(v) / (b.Mag() * v.Mag())
+}
+
+// Add adds a vector to this one. The result generally isn't binary, so it is
+// returned as a SparseVectorUint32.
+func (b *BitmapVector) Add(v Vector) Vector {
+	return b.ToSparseVector().Add(toSparseVectorUint32(v))
+}
+
+// Sub subtracts a vector from this one. The result generally isn't binary, so
+// it is returned as a SparseVectorUint32.
+func (b *BitmapVector) Sub(v Vector) Vector {
+	return b.ToSparseVector().Sub(toSparseVectorUint32(v))
+}
+
+func toSparseVectorUint32(v Vector) Vector {
+	if b, ok := v.(*BitmapVector); ok {
+		return b.ToSparseVector()
+	}
+	return v
+}
+
+// Mult multiplies the vector by a constant. As all the values are the same
+// the result is still a BitmapVector. The vector is modified in place.
+func (b *BitmapVector) Mult(l Value) {
+	b.weight *= l
+	debugCheck(b)
+}
+
+var _ Vector = (*BitmapVector)(nil)
@@ -0,0 +1,224 @@
+package sparsevector
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func BenchmarkBitmapVector1000(b *testing.B) {
+	benchmarkBitmapVectorM(b, 1000)
+}
+
+func BenchmarkBitmapVector10000(b *testing.B) {
+	benchmarkBitmapVectorM(b, 10000)
+}
+
+func benchmarkBitmapVectorM(b *testing.B, m int) {
+	// Generate 2 sparse vectors using 75% of numbers 1-m
+	v1 := NewBitmapVector(genRandomSparseVector(m).indices)
+	v2 := NewBitmapVector(genRandomSparseVector(m).indices)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var total Value
+	for i := 0; i < b.N; i++ {
+		result := v1.Cos(v2)
+		total += result
+	}
+}
+
+// genBitmapTestIndices generates indices that land in array, bitmap and run
+// containers
+func genBitmapTestIndices(rnd *rand.Rand) []uint32 {
+	var indices []uint32
+	// Sparse - array containers
+	for i := 0; i < 200; i++ {
+		indices = append(indices, uint32(rnd.Intn(1<<20)))
+	}
+	// Dense - a bitmap container
+	base := uint32(rnd.Intn(16)+32) << 16
+	for i := 0; i < 20000; i++ {
+		indices = append(indices, base+uint32(rnd.Intn(1<<16)))
+	}
+	// Runs
+	base = uint32(rnd.Intn(16)+64) << 16
+	for r := 0; r < 10; r++ {
+		start := base + uint32(rnd.Intn(60000))
+		for i := uint32(0); i < uint32(rnd.Intn(2000)); i++ {
+			indices = append(indices, start+i)
+		}
+	}
+	return indices
+}
+
+func TestBitmapVectorContainers(t *testing.T) {
+	b := NewBitmapVector(genBitmapTestIndices(rand.New(rand.NewSource(1))))
+	var array, bitset, run int
+	for _, c := range b.containers {
+		switch c.(type) {
+		case arrayContainer:
+			array++
+		case bitsetContainer:
+			bitset++
+		case runContainer:
+			run++
+		}
+	}
+	if array == 0 || bitset != 1 || run != 1 {
+		t.Errorf("containers not as expected. %d array, %d bitset, %d run", array, bitset, run)
+	}
+}
+
+func TestBitmapVector(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	for i := 0; i < 10; i++ {
+		indices1 := genBitmapTestIndices(rnd)
+		indices2 := genBitmapTestIndices(rnd)
+		b1 := NewBitmapVector(indices1)
+		b2 := NewBitmapVector(indices2)
+
+		// Compare with a map of the indices
+		set1 := make(map[uint32]bool)
+		for _, index := range indices1 {
+			set1[index] = true
+		}
+		set2 := make(map[uint32]bool)
+		for _, index := range indices2 {
+			set2[index] = true
+		}
+		var common int
+		for index := range set1 {
+			if set2[index] {
+				common++
+			}
+		}
+
+		if b1.Cardinality() != len(set1) {
+			t.Errorf("Test %d. Cardinality is %d, expected %d", i, b1.Cardinality(), len(set1))
+		}
+		if dp := b1.Dot(b2); dp != Value(common) {
+			t.Errorf("Test %d. Dot is %f, expected %d", i, dp, common)
+		}
+		if dp := b2.Dot(b1); dp != Value(common) {
+			t.Errorf("Test %d. Reverse Dot is %f, expected %d", i, dp, common)
+		}
+		if mag := b1.Mag(); mag != Value(math.Sqrt(float64(len(set1)))) {
+			t.Errorf("Test %d. Mag is %f", i, mag)
+		}
+
+		sv1 := b1.ToSparseVector()
+		if len(sv1.indices) != len(set1) {
+			t.Fatalf("Test %d. ToSparseVector has %d entries, expected %d", i, len(sv1.indices), len(set1))
+		}
+		for j, index := range sv1.indices {
+			if !set1[index] || (j > 0 && index <= sv1.indices[j-1]) || sv1.values[j] != 1 {
+				t.Fatalf("Test %d. ToSparseVector bad at %d", i, j)
+			}
+			if !b1.Contains(index) {
+				t.Fatalf("Test %d. Contains(%d) is false", i, index)
+			}
+		}
+		if b1.Contains(1 << 31) {
+			t.Errorf("Test %d. Contains true for missing index", i)
+		}
+
+		// Mixed Dot against a SparseVectorUint32
+		sv2 := b2.ToSparseVector()
+		sv2.Mult(0.5)
+		if dp, exp := b1.Dot(sv2), sv1.Dot(sv2); dp != exp {
+			t.Errorf("Test %d. Mixed Dot is %f, expected %f", i, dp, exp)
+		}
+		if dp, exp := sv2.Dot(b1), sv2.Dot(sv1); dp != exp {
+			t.Errorf("Test %d. Reverse mixed Dot is %f, expected %f", i, dp, exp)
+		}
+		if cos, exp := b1.Cos(sv2), sv1.Cos(sv2); math.Abs(float64(cos-exp)) > 1e-6 {
+			t.Errorf("Test %d. Mixed Cos is %f, expected %f", i, cos, exp)
+		}
+	}
+}
+
+func TestBitmapVectorOps(t *testing.T) {
+	b1 := NewBitmapVector([]uint32{3, 1, 2, 2})
+	b2 := NewBitmapVector([]uint32{1, 3, 4})
+
+	sum := b1.Add(b2)
+	if !reflect.DeepEqual(NewSparseVectorUint32([]uint32{1, 2, 3, 4}, []Value{2, 1, 2, 1}), sum) {
+		t.Errorf("Sum not as expected. Have %v", sum)
+	}
+	diff := b1.Sub(NewSparseVectorUint32([]uint32{1, 4}, []Value{3, 1}))
+	if !reflect.DeepEqual(NewSparseVectorUint32([]uint32{1, 2, 3, 4}, []Value{-2, 1, 1, -1}), diff) {
+		t.Errorf("Difference not as expected. Have %v", diff)
+	}
+
+	b1.Mult(2)
+	if dp := b1.Dot(b2); dp != 4 {
+		t.Errorf("Dot after Mult is %f", dp)
+	}
+	if mag := b1.Mag(); mag != Value(2*math.Sqrt(3)) {
+		t.Errorf("Mag after Mult is %f", mag)
+	}
+	var values []Value
+	b1.Iter(func(index uint32, value Value) { values = append(values, value) })
+	if !reflect.DeepEqual([]Value{2, 2, 2}, values) {
+		t.Errorf("Iter values not as expected. Have %v", values)
+	}
+}
+
+func TestBitmapIntersectionCount(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+
+	// Build the same sets as each type of container, so we check every
+	// combination
+	asContainers := func(lows []uint16) []bitmapContainer {
+		bitset := make(bitsetContainer, 1024)
+		var runs runContainer
+		for i, low := range lows {
+			bitset[low/64] |= 1 << (low % 64)
+			if i == 0 || low != lows[i-1]+1 {
+				runs = append(runs, bitmapRun{start: low})
+			} else {
+				runs[len(runs)-1].length++
+			}
+		}
+		return []bitmapContainer{arrayContainer(lows), bitset, runs}
+	}
+	genLows := func() []uint16 {
+		set := make([]bool, 1<<16)
+		for r := 0; r < 20; r++ {
+			start, end := rnd.Intn(1<<16), rnd.Intn(500)
+			for i := start; i < start+end && i < 1<<16; i++ {
+				set[i] = true
+			}
+		}
+		var lows []uint16
+		for i, ok := range set {
+			if ok {
+				lows = append(lows, uint16(i))
+			}
+		}
+		return lows
+	}
+
+	for i := 0; i < 20; i++ {
+		lows1, lows2 := genLows(), genLows()
+		var exp int
+		for _, low := range lows1 {
+			if arrayContainer(lows2).contains(low) {
+				exp++
+			}
+		}
+		for _, c1 := range asContainers(lows1) {
+			if c1.cardinality() != len(lows1) {
+				t.Errorf("Test %d. %T has cardinality %d, expected %d", i, c1, c1.cardinality(), len(lows1))
+			}
+			for _, c2 := range asContainers(lows2) {
+				if count := intersectionCount(c1, c2); count != exp {
+					t.Errorf("Test %d. %T with %T has intersection %d, expected %d", i, c1, c2, count, exp)
+				}
+			}
+		}
+	}
+}
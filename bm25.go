@@ -0,0 +1,151 @@
+package sparsevector
+
+import "math"
+
+// BM25 scores documents against queries using the Okapi BM25 ranking
+// function. Documents and queries are count vectors. It is first fitted on a
+// corpus of documents to collect the document frequency of each index and the
+// average document length, where the length of a document is the sum of its
+// counts.
+//
+// The score of a document for a query is the sum over the indices they share
+// of
+//
+//	q * idf * f * (K1 + 1) / (f + K1 * (1 - B + B * len / avglen))
+//
+// where q is the query count, f is the document count, and idf is
+// ln(1 + (N - df + 0.5) / (df + 0.5)) for a corpus of N documents.
+//
+// The zero value is ready to use, but has K1 and B zero, so ignores repeated
+// occurrences and document length. NewBM25 sets the usual parameters.
+type BM25 struct {
+	// K1 controls how quickly repeated occurrences stop adding to the score.
+	// NewBM25 sets it to 1.2.
+	K1 Value
+	// B controls how much longer documents are penalized, from 0 for no
+	// penalty to 1. NewBM25 sets it to 0.75.
+	B Value
+
+	docs     int
+	totalLen float64
+	df       map[uint32]int
+}
+
+// NewBM25 creates an unfitted BM25 with the usual parameters
+func NewBM25() *BM25 {
+	return &BM25{
+		K1: 1.2,
+		B:  0.75,
+		df: make(map[uint32]int),
+	}
+}
+
+// Fit adds a document to the corpus statistics. Call it for each document in
+// the corpus.
+func (s *BM25) Fit(doc *SparseVectorUint32) {
+	if s.df == nil {
+		s.df = make(map[uint32]int)
+	}
+	for _, index := range doc.indices {
+		s.df[index]++
+	}
+	s.totalLen += float64(docLen(doc))
+	s.docs++
+}
+
+// docLen is the length of a document, the sum of its counts
+func docLen(doc *SparseVectorUint32) Value {
+	var l Value
+	for _, v := range doc.values {
+		l += v
+	}
+	return l
+}
+
+// Docs returns the number of documents in the corpus
+func (s *BM25) Docs() int { return s.docs }
+
+// AvgLen returns the average length of the documents in the corpus
+func (s *BM25) AvgLen() Value {
+	if s.docs == 0 {
+		return 0
+	}
+	return Value(s.totalLen / float64(s.docs))
+}
+
+// Idf returns the inverse document frequency of an index
+func (s *BM25) Idf(index uint32) Value {
+	df := float64(s.df[index])
+	return Value(math.Log(1 + (float64(s.docs)-df+0.5)/(df+0.5)))
+}
+
+// termWeight returns the weight of an index that occurs count times in a
+// document with length normalization norm. An index with a count of zero
+// has no weight, even when K1 is zero.
+func (s *BM25) termWeight(index uint32, count, norm Value) Value {
+	if count == 0 {
+		return 0
+	}
+	return s.Idf(index) * count * (s.K1 + 1) / (count + s.K1*norm)
+}
+
+// lengthNorm is the length normalization for a document
+func (s *BM25) lengthNorm(doc *SparseVectorUint32) Value {
+	avg := s.AvgLen()
+	if avg == 0 {
+		return 1
+	}
+	return 1 - s.B + s.B*docLen(doc)/avg
+}
+
+// Score returns the BM25 score of a document for a query
+func (s *BM25) Score(query, doc *SparseVectorUint32) Value {
+	norm := s.lengthNorm(doc)
+	var score Value
+	var i1, i2 int
+	for i1 < len(query.indices) && i2 < len(doc.indices) {
+		if query.indices[i1] < doc.indices[i2] {
+			i1++
+		} else if doc.indices[i2] < query.indices[i1] {
+			i2++
+		} else {
+			score += query.values[i1] * s.termWeight(doc.indices[i2], doc.values[i2], norm)
+			i1++
+			i2++
+		}
+	}
+	return score
+}
+
+// Weight returns a copy of a document with each count replaced by its BM25
+// term weight. The BM25 score of the document for a query is then the dot
+// product of the query and the weighted document, so weighted documents can be
+// stored in anything that ranks by dot product, such as an InvertedIndex.
+func (s *BM25) Weight(doc *SparseVectorUint32) *SparseVectorUint32 {
+	norm := s.lengthNorm(doc)
+	out := &SparseVectorUint32{
+		indices: make([]uint32, len(doc.indices)),
+		values:  make([]Value, len(doc.values)),
+	}
+	copy(out.indices, doc.indices)
+	for i, index := range doc.indices {
+		out.values[i] = s.termWeight(index, doc.values[i], norm)
+	}
+	return out
+}
+
+// Index builds an InvertedIndex of documents weighted by Weight, so that the
+// dot products found by the index's Dots are the BM25 scores of the
+// documents for a query. The ids in the index are the positions of the
+// documents in docs. Documents that share no index with a query score zero,
+// and Dots leaves them out.
+//
+// The weights depend on the corpus statistics, so build the index again after
+// fitting more documents.
+func (s *BM25) Index(docs []*SparseVectorUint32) *InvertedIndex {
+	ix := NewInvertedIndex()
+	for _, doc := range docs {
+		ix.Add(s.Weight(doc))
+	}
+	return ix
+}
@@ -0,0 +1,101 @@
+package sparsevector
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBM25(t *testing.T) {
+	corpus := []*SparseVectorUint32{
+		NewSparseVectorUint32([]uint32{1, 2}, []Value{2, 2}),
+		NewSparseVectorUint32([]uint32{1, 3}, []Value{1, 1}),
+		NewSparseVectorUint32([]uint32{2, 3, 4}, []Value{1, 3, 2}),
+	}
+	s := NewBM25()
+	for _, doc := range corpus {
+		s.Fit(doc)
+	}
+	if s.Docs() != 3 || s.AvgLen() != 4 {
+		t.Fatalf("stats not as expected. %d docs, average length %f", s.Docs(), s.AvgLen())
+	}
+
+	query := NewSparseVectorUint32([]uint32{2, 4, 5}, []Value{1, 2, 1})
+	for i, doc := range corpus {
+		// Calculate the score directly from the formula
+		dl := 0.0
+		for _, v := range doc.values {
+			dl += float64(v)
+		}
+		var exp float64
+		for j, index := range query.indices {
+			var f float64
+			for k, di := range doc.indices {
+				if di == index {
+					f = float64(doc.values[k])
+				}
+			}
+			if f == 0 {
+				continue
+			}
+			var df float64
+			for _, d := range corpus {
+				for _, di := range d.indices {
+					if di == index {
+						df++
+					}
+				}
+			}
+			idf := math.Log(1 + (3-df+0.5)/(df+0.5))
+			exp += float64(query.values[j]) * idf * f * 2.2 / (f + 1.2*(0.25+0.75*dl/4))
+		}
+
+		if score := s.Score(query, doc); math.Abs(float64(score)-exp) > 1e-5 {
+			t.Errorf("Test %d. Score is %f, expected %f", i, score, exp)
+		}
+		if dp := query.Dot(s.Weight(doc)); math.Abs(float64(dp)-exp) > 1e-5 {
+			t.Errorf("Test %d. Dot with weighted document is %f, expected %f", i, dp, exp)
+		}
+	}
+
+	// The index finds the same scores
+	ids, scores := s.Index(corpus).Dots(query)
+	for k, id := range ids {
+		if exp := s.Score(query, corpus[id]); math.Abs(float64(scores[k]-exp)) > 1e-5 {
+			t.Errorf("Index score for %d is %f, expected %f", id, scores[k], exp)
+		}
+	}
+	if len(ids) != 2 || ids[0] != 0 || ids[1] != 2 {
+		t.Errorf("Index found %v", ids)
+	}
+
+	// Rarer indices have higher idf
+	if s.Idf(4) <= s.Idf(1) || s.Idf(5) <= s.Idf(4) {
+		t.Errorf("idf not decreasing with frequency. %f %f %f", s.Idf(1), s.Idf(4), s.Idf(5))
+	}
+}
+
+func TestBM25ZeroValue(t *testing.T) {
+	// With K1 and B zero each shared index scores its query count times idf
+	var s BM25
+	if s.AvgLen() != 0 {
+		t.Errorf("unfitted average length is %f", s.AvgLen())
+	}
+	s.Fit(NewSparseVectorUint32([]uint32{1, 2}, []Value{2, 2}))
+	s.Fit(NewSparseVectorUint32([]uint32{1}, []Value{5}))
+
+	query := NewSparseVectorUint32([]uint32{1, 2}, []Value{1, 3})
+	doc := NewSparseVectorUint32([]uint32{1, 2}, []Value{4, 1})
+	exp := s.Idf(1) + 3*s.Idf(2)
+	if score := s.Score(query, doc); math.Abs(float64(score-exp)) > 1e-6 {
+		t.Errorf("Score is %f, expected %f", score, exp)
+	}
+
+	// An explicit zero count scores nothing, rather than 0/0
+	doc = &SparseVectorUint32{indices: []uint32{1, 2}, values: []Value{0, 1}}
+	if score := s.Score(query, doc); !(math.Abs(float64(score-3*s.Idf(2))) <= 1e-6) {
+		t.Errorf("Score with a zero count is %f, expected %f", score, 3*s.Idf(2))
+	}
+	if w := s.Weight(doc); w.values[0] != 0 {
+		t.Errorf("Weight of a zero count is %f", w.values[0])
+	}
+}
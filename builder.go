@@ -0,0 +1,142 @@
+package sparsevector
+
+import "sort"
+
+// Builder builds a SparseVectorUint32 one entry at a time, for example from a
+// stream of events. Values added for the same index are summed, in the order
+// they were added.
+//
+// A Builder can be reused after Reset, keeping its allocated space, so
+// builders can be kept in a sync.Pool.
+type Builder struct {
+	indices []uint32
+	values  []Value
+	// sorted is true while the indices have been added in increasing order
+	sorted bool
+}
+
+// NewBuilder creates an empty Builder
+func NewBuilder() *Builder {
+	return &Builder{sorted: true}
+}
+
+// Reserve makes space for n more entries without reallocating
+func (b *Builder) Reserve(n int) {
+	if cap(b.indices)-len(b.indices) < n {
+		indices := make([]uint32, len(b.indices), len(b.indices)+n)
+		copy(indices, b.indices)
+		b.indices = indices
+		values := make([]Value, len(b.values), len(b.values)+n)
+		copy(values, b.values)
+		b.values = values
+	}
+}
+
+// Add adds a value at an index
+func (b *Builder) Add(index uint32, value Value) {
+	if l := len(b.indices); l > 0 && index < b.indices[l-1] {
+		b.sorted = false
+	}
+	b.indices = append(b.indices, index)
+	b.values = append(b.values, value)
+}
+
+// Len returns the number of entries added since the builder was created or
+// reset, including repeated indices
+func (b *Builder) Len() int { return len(b.indices) }
+
+// Build returns a vector holding the sum of the values added at each index.
+// The builder is not reset, so more entries can be added and Build called
+// again.
+func (b *Builder) Build() *SparseVectorUint32 {
+	if !b.sorted {
+		// Stable so repeated indices are summed in the order they were added
+		sort.Stable(sparseVectorUint32Sort{&SparseVectorUint32{indices: b.indices, values: b.values}})
+		b.sorted = true
+	}
+	sv := &SparseVectorUint32{
+		indices: append([]uint32(nil), b.indices...),
+		values:  append([]Value(nil), b.values...),
+	}
+	sv.mergeDuplicates()
+	debugCheck(sv)
+	return sv
+}
+
+// Reset empties the builder, keeping its allocated space
+func (b *Builder) Reset() {
+	b.indices = b.indices[:0]
+	b.values = b.values[:0]
+	b.sorted = true
+}
+
+// GenBuilder builds a GenSparseVector one entry at a time. Values added for
+// the same index are summed, in the order they were added.
+type GenBuilder struct {
+	index  VectorIndex
+	values []Value
+}
+
+// NewGenBuilder creates an empty GenBuilder. index is an index of the type to
+// build, such as StringIndex(nil). It is not modified.
+func NewGenBuilder(index VectorIndex) *GenBuilder {
+	return &GenBuilder{index: index.New(0)}
+}
+
+// Reserve makes space for n more entries. As VectorIndex has no way to grow
+// an index, space is only reserved when the builder is empty.
+func (b *GenBuilder) Reserve(n int) {
+	if len(b.values) == 0 {
+		b.index = b.index.New(n)
+		b.values = make([]Value, 0, n)
+	}
+}
+
+// Add adds a value at an index. The index must be of the right type for the
+// builder's VectorIndex.
+func (b *GenBuilder) Add(index interface{}, value Value) {
+	b.index = b.index.Append(index)
+	b.values = append(b.values, value)
+}
+
+// Len returns the number of entries added since the builder was created or
+// reset, including repeated indices
+func (b *GenBuilder) Len() int { return len(b.values) }
+
+// Build returns a vector holding the sum of the values added at each index.
+// The builder is not reset, so more entries can be added and Build called
+// again.
+func (b *GenBuilder) Build() *GenSparseVector {
+	// Stable so repeated indices are summed in the order they were added
+	sort.Stable(genSparseVectorSort{&GenSparseVector{index: b.index, values: b.values}})
+
+	index := b.index.New(len(b.values))
+	values := make([]Value, 0, len(b.values))
+	for i, value := range b.values {
+		if i > 0 && !b.index.Less(i-1, i) {
+			values[len(values)-1] += value
+			continue
+		}
+		index = index.Append(b.index.GetAtLocation(i))
+		values = append(values, value)
+	}
+	gsv := &GenSparseVector{index: index, values: values}
+	debugCheck(gsv)
+	return gsv
+}
+
+// Reset empties the builder. The space allocated for Uint32Index, IntIndex
+// and StringIndex indices is kept, while other index types are recreated.
+func (b *GenBuilder) Reset() {
+	switch index := b.index.(type) {
+	case Uint32Index:
+		b.index = index[:0]
+	case IntIndex:
+		b.index = index[:0]
+	case StringIndex:
+		b.index = index[:0]
+	default:
+		b.index = b.index.New(cap(b.values))
+	}
+	b.values = b.values[:0]
+}
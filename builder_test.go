@@ -0,0 +1,128 @@
+package sparsevector
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func BenchmarkBuilder(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	indices := make([]uint32, 10000)
+	for i := range indices {
+		indices[i] = uint32(rnd.Intn(5000))
+	}
+	pool := sync.Pool{New: func() interface{} { return NewBuilder() }}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		builder := pool.Get().(*Builder)
+		for _, index := range indices {
+			builder.Add(index, 1)
+		}
+		builder.Build()
+		builder.Reset()
+		pool.Put(builder)
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder()
+	b.Reserve(10)
+	if cap(b.indices) < 10 || cap(b.values) < 10 {
+		t.Errorf("Reserve did not make space. Capacity %d", cap(b.indices))
+	}
+
+	b.Add(5, 1)
+	b.Add(2, 2)
+	b.Add(5, 3)
+	b.Add(9, 4)
+	b.Add(2, -2)
+	if b.Len() != 5 {
+		t.Errorf("Len is %d", b.Len())
+	}
+	sv := b.Build()
+	if !reflect.DeepEqual(NewSparseVectorUint32([]uint32{2, 5, 9}, []Value{0, 4, 4}), sv) {
+		t.Errorf("Vector not as expected. Have %v", sv)
+	}
+
+	// Keep adding, then build again
+	b.Add(1, 1)
+	if sv := b.Build(); !reflect.DeepEqual(NewSparseVectorUint32([]uint32{1, 2, 5, 9}, []Value{1, 0, 4, 4}), sv) {
+		t.Errorf("Second vector not as expected. Have %v", sv)
+	}
+
+	// The first vector is not affected by reusing the builder
+	b.Reset()
+	b.Add(7, 7)
+	b.Add(8, 8)
+	if sv2 := b.Build(); !reflect.DeepEqual(NewSparseVectorUint32([]uint32{7, 8}, []Value{7, 8}), sv2) {
+		t.Errorf("Vector after Reset not as expected. Have %v", sv2)
+	}
+	if !reflect.DeepEqual(NewSparseVectorUint32([]uint32{2, 5, 9}, []Value{0, 4, 4}), sv) {
+		t.Errorf("First vector changed. Have %v", sv)
+	}
+
+	b.Reset()
+	if sv := b.Build(); len(sv.indices) != 0 || len(sv.values) != 0 {
+		t.Errorf("Empty vector not as expected. Have %v", sv)
+	}
+}
+
+func TestBuilderRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	b := NewBuilder()
+	for i := 0; i < 10; i++ {
+		b.Reset()
+		sums := make(map[uint32]Value)
+		for j := 0; j < 1000; j++ {
+			index := uint32(rnd.Intn(200))
+			value := Value(rnd.Intn(8))
+			b.Add(index, value)
+			sums[index] += value
+		}
+		sv := b.Build()
+		if len(sv.indices) != len(sums) {
+			t.Fatalf("Test %d. Have %d entries, expected %d", i, len(sv.indices), len(sums))
+		}
+		for j, index := range sv.indices {
+			if sv.values[j] != sums[index] {
+				t.Errorf("Test %d. Value at %d is %f, expected %f", i, index, sv.values[j], sums[index])
+			}
+		}
+		if err := sv.Validate(); err != nil {
+			t.Errorf("Test %d. %v", i, err)
+		}
+	}
+}
+
+func TestGenBuilder(t *testing.T) {
+	b := NewGenBuilder(StringIndex(nil))
+	b.Reserve(4)
+	b.Add("b", 1)
+	b.Add("a", 2)
+	b.Add("b", 3)
+	b.Add("c", 4)
+	if b.Len() != 4 {
+		t.Errorf("Len is %d", b.Len())
+	}
+	gsv := b.Build()
+	if !reflect.DeepEqual(NewGenSparseVector(StringIndex{"a", "b", "c"}, []Value{2, 4, 4}), gsv) {
+		t.Errorf("Vector not as expected. Have %v", gsv)
+	}
+
+	b.Reset()
+	if index := b.index.(StringIndex); cap(index) == 0 || cap(b.values) == 0 {
+		t.Errorf("Reset did not keep the allocated space")
+	}
+	b.Add("z", 1)
+	if gsv2 := b.Build(); !reflect.DeepEqual(NewGenSparseVector(StringIndex{"z"}, []Value{1}), gsv2) {
+		t.Errorf("Vector after Reset not as expected. Have %v", gsv2)
+	}
+	if !reflect.DeepEqual(NewGenSparseVector(StringIndex{"a", "b", "c"}, []Value{2, 4, 4}), gsv) {
+		t.Errorf("First vector changed. Have %v", gsv)
+	}
+}
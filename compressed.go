@@ -0,0 +1,375 @@
+package sparsevector
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// compressedBlockSize is the number of entries in each block of a
+// CompressedSparseVector
+const compressedBlockSize = 128
+
+// CompressedSparseVector is a sparse vector with uint32 indices that uses
+// much less memory than SparseVectorUint32.
+//
+// Indices are stored in blocks of 128. Within a block the differences between
+// consecutive indices are bit-packed using only as many bits as the largest
+// difference needs. Each block has a skip pointer recording its first and last
+// index, so Dot and Cos can skip whole blocks that can't intersect the other
+// vector and only unpack the blocks that might.
+//
+// Values are either stored as Value, or quantized to a single byte each. Dot
+// products using quantized vectors are approximate.
+type CompressedSparseVector struct {
+	blocks []compressedBlock
+	packed []uint32
+	// Exactly one of values and qvalues is set
+	values  []Value
+	qvalues []uint8
+	qmin    Value
+	qscale  Value
+	length  int
+
+	mag      Value
+	magClean bool
+}
+
+// compressedBlock is the skip pointer for a block of indices
+type compressedBlock struct {
+	first uint32
+	last  uint32
+	// offset is where this block's packed differences start in packed
+	offset uint32
+	// width is the number of bits used for each difference
+	width uint8
+}
+
+// NewCompressedSparseVector creates a CompressedSparseVector holding the same
+// values as sv.
+func NewCompressedSparseVector(sv *SparseVectorUint32) *CompressedSparseVector {
+	c := compressIndices(sv.indices)
+	c.values = append([]Value(nil), sv.values...)
+	debugCheck(c)
+	return c
+}
+
+// NewQuantizedCompressedSparseVector creates a CompressedSparseVector from sv
+// with each value quantized to 8 bits. The values are spread linearly between
+// the minimum and maximum values in sv.
+func NewQuantizedCompressedSparseVector(sv *SparseVectorUint32) *CompressedSparseVector {
+	c := compressIndices(sv.indices)
+	c.quantize(sv.values)
+	debugCheck(c)
+	return c
+}
+
+func compressIndices(indices []uint32) *CompressedSparseVector {
+	c := &CompressedSparseVector{length: len(indices)}
+	nblocks := (len(indices) + compressedBlockSize - 1) / compressedBlockSize
+	c.blocks = make([]compressedBlock, nblocks)
+	for b := range c.blocks {
+		start := b * compressedBlockSize
+		end := start + compressedBlockSize
+		if end > len(indices) {
+			end = len(indices)
+		}
+		block := indices[start:end]
+
+		var maxDelta uint32
+		for i := 1; i < len(block); i++ {
+			if d := block[i] - block[i-1]; d > maxDelta {
+				maxDelta = d
+			}
+		}
+		width := uint(bits.Len32(maxDelta))
+		c.blocks[b] = compressedBlock{
+			first:  block[0],
+			last:   block[len(block)-1],
+			offset: uint32(len(c.packed)),
+			width:  uint8(width),
+		}
+
+		// Pack the differences
+		nwords := ((len(block)-1)*int(width) + 31) / 32
+		words := make([]uint32, nwords)
+		var pos uint
+		for i := 1; i < len(block); i++ {
+			d := block[i] - block[i-1]
+			word, shift := pos/32, pos%32
+			words[word] |= d << shift
+			if shift+width > 32 {
+				words[word+1] |= d >> (32 - shift)
+			}
+			pos += width
+		}
+		c.packed = append(c.packed, words...)
+	}
+	return c
+}
+
+func (c *CompressedSparseVector) quantize(values []Value) {
+	c.values = nil
+	c.qvalues = make([]uint8, len(values))
+	if len(values) == 0 {
+		return
+	}
+	min, max := values[0], values[0]
+	for _, v := range values {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+	c.qmin = min
+	c.qscale = (max - min) / 255
+	if c.qscale == 0 {
+		return
+	}
+	for i, v := range values {
+		c.qvalues[i] = uint8(math.Round(float64((v - min) / c.qscale)))
+	}
+}
+
+// unpackBlock unpacks the indices of block b into buf, returning the indices
+func (c *CompressedSparseVector) unpackBlock(b int, buf *[compressedBlockSize]uint32) []uint32 {
+	block := &c.blocks[b]
+	n := compressedBlockSize
+	if b == len(c.blocks)-1 {
+		n = c.length - b*compressedBlockSize
+	}
+	out := buf[:n]
+	out[0] = block.first
+
+	width := uint(block.width)
+	if width == 0 {
+		for i := 1; i < n; i++ {
+			out[i] = block.first
+		}
+		return out
+	}
+	mask := uint32(1<<width - 1)
+	words := c.packed[block.offset:]
+	var pos uint
+	prev := block.first
+	for i := 1; i < n; i++ {
+		word, shift := pos/32, pos%32
+		d := words[word] >> shift
+		if shift+width > 32 {
+			d |= words[word+1] << (32 - shift)
+		}
+		prev += d & mask
+		out[i] = prev
+		pos += width
+	}
+	return out
+}
+
+// value returns the value of entry i
+func (c *CompressedSparseVector) value(i int) Value {
+	if c.qvalues != nil {
+		return c.qmin + Value(c.qvalues[i])*c.qscale
+	}
+	return c.values[i]
+}
+
+// Len returns the number of entries in the vector
+func (c *CompressedSparseVector) Len() int { return c.length }
+
+// Decompress returns the vector as a SparseVectorUint32
+func (c *CompressedSparseVector) Decompress() *SparseVectorUint32 {
+	indices := make([]uint32, 0, c.length)
+	var buf [compressedBlockSize]uint32
+	for b := range c.blocks {
+		indices = append(indices, c.unpackBlock(b, &buf)...)
+	}
+	values := make([]Value, c.length)
+	for i := range values {
+		values[i] = c.value(i)
+	}
+	return &SparseVectorUint32{indices: indices, values: values}
+}
+
+// Iter lets you iterate over the members of the sparse vector
+func (c *CompressedSparseVector) Iter(f func(index uint32, value Value)) {
+	var buf [compressedBlockSize]uint32
+	for b := range c.blocks {
+		base := b * compressedBlockSize
+		for i, index := range c.unpackBlock(b, &buf) {
+			f(index, c.value(base+i))
+		}
+	}
+}
+
+// Mag returns the magnitude of the vector. It is calculated lazily and cached.
+func (c *CompressedSparseVector) Mag() Value {
+	if !c.magClean {
+		var magsq Value
+		for i := 0; i < c.length; i++ {
+			val := c.value(i)
+			magsq += val * val
+		}
+		c.mag = Value(math.Sqrt(float64(magsq)))
+		c.magClean = true
+	}
+	return c.mag
+}
+
+// Dot calculates the dot product of this vector and another. The other vector
+// may be a CompressedSparseVector or a SparseVectorUint32.
+func (c *CompressedSparseVector) Dot(v Vector) Value {
+	switch v := v.(type) {
+	case *CompressedSparseVector:
+		return c.dotCompressed(v)
+	case *SparseVectorUint32:
+		return c.dotSparse(v)
+	}
+	panic("sparsevector: CompressedSparseVector.Dot needs a CompressedSparseVector or SparseVectorUint32")
+}
+
+func (c1 *CompressedSparseVector) dotCompressed(c2 *CompressedSparseVector) Value {
+	var buf1, buf2 [compressedBlockSize]uint32
+	var idx1, idx2 []uint32
+	var dp Value
+
+	// b1, b2 are the current blocks, and p1, p2 our position within them.
+	// unpacked1 and unpacked2 note which blocks are in the buffers.
+	b1, b2 := 0, 0
+	var p1, p2 int
+	unpacked1, unpacked2 := -1, -1
+	for b1 < len(c1.blocks) && b2 < len(c2.blocks) {
+		blk1, blk2 := &c1.blocks[b1], &c2.blocks[b2]
+		if blk1.last < blk2.first {
+			b1++
+			p1 = 0
+			continue
+		}
+		if blk2.last < blk1.first {
+			b2++
+			p2 = 0
+			continue
+		}
+
+		// The blocks overlap, so we need to look inside them
+		if unpacked1 != b1 {
+			idx1 = c1.unpackBlock(b1, &buf1)
+			unpacked1 = b1
+		}
+		if unpacked2 != b2 {
+			idx2 = c2.unpackBlock(b2, &buf2)
+			unpacked2 = b2
+		}
+		base1, base2 := b1*compressedBlockSize, b2*compressedBlockSize
+		for p1 < len(idx1) && p2 < len(idx2) {
+			if idx1[p1] < idx2[p2] {
+				p1++
+			} else if idx2[p2] < idx1[p1] {
+				p2++
+			} else {
+				dp += c1.value(base1+p1) * c2.value(base2+p2)
+				p1++
+				p2++
+			}
+		}
+
+		// Move on from whichever block finishes first
+		last1, last2 := blk1.last, blk2.last
+		if last1 <= last2 {
+			b1++
+			p1 = 0
+		}
+		if last2 <= last1 {
+			b2++
+			p2 = 0
+		}
+	}
+	return dp
+}
+
+func (c *CompressedSparseVector) dotSparse(sv *SparseVectorUint32) Value {
+	var buf [compressedBlockSize]uint32
+	var dp Value
+	var p2 int
+	l2 := len(sv.indices)
+	for b := range c.blocks {
+		if p2 >= l2 {
+			break
+		}
+		blk := &c.blocks[b]
+		// Skip forward to the first entry of sv that could be in this block
+		if sv.indices[p2] < blk.first {
+			rest := sv.indices[p2:]
+			p2 += sort.Search(len(rest), func(i int) bool { return rest[i] >= blk.first })
+			if p2 >= l2 {
+				break
+			}
+		}
+		if sv.indices[p2] > blk.last {
+			continue
+		}
+
+		idx := c.unpackBlock(b, &buf)
+		base := b * compressedBlockSize
+		var p1 int
+		for p1 < len(idx) && p2 < l2 {
+			if idx[p1] < sv.indices[p2] {
+				p1++
+			} else if sv.indices[p2] < idx[p1] {
+				p2++
+			} else {
+				dp += c.value(base+p1) * sv.values[p2]
+				p1++
+				p2++
+			}
+		}
+	}
+	return dp
+}
+
+// Cos calculates the cosine of the angle between this vector and another.
+// The other vector may be a CompressedSparseVector or a SparseVectorUint32.
+func (c *CompressedSparseVector) Cos(v Vector) Value {
+	return c.Dot(v) / (c.Mag() * v.Mag())
+}
+
+// Add adds a vector to this one. The result is a CompressedSparseVector, and
+// is quantized if this vector is.
+func (c *CompressedSparseVector) Add(v Vector) Vector {
+	return c.runOp(v, AddOp)
+}
+
+// Sub subtracts a vector from this one. The result is a
+// CompressedSparseVector, and is quantized if this vector is.
+func (c *CompressedSparseVector) Sub(v Vector) Vector {
+	return c.runOp(v, SubOp)
+}
+
+func (c *CompressedSparseVector) runOp(v Vector, op ValueOp) Vector {
+	if vc, ok := v.(*CompressedSparseVector); ok {
+		v = vc.Decompress()
+	}
+	result := c.Decompress().runOp(v, op).(*SparseVectorUint32)
+	if c.qvalues != nil {
+		return NewQuantizedCompressedSparseVector(result)
+	}
+	out := compressIndices(result.indices)
+	out.values = result.values
+	return out
+}
+
+// Mult multiplies the vector by a constant. The vector is modified in place
+func (c *CompressedSparseVector) Mult(l Value) {
+	if c.qvalues != nil {
+		c.qmin *= l
+		c.qscale *= l
+	}
+	for i, v := range c.values {
+		c.values[i] = l * v
+	}
+	c.magClean = false
+	debugCheck(c)
+}
+
+var _ Vector = (*CompressedSparseVector)(nil)
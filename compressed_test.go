@@ -0,0 +1,156 @@
+package sparsevector
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func BenchmarkCompressedSparseVector1000(b *testing.B) {
+	benchmarkCompressedSparseVectorM(b, 1000)
+}
+
+func BenchmarkCompressedSparseVector10000(b *testing.B) {
+	benchmarkCompressedSparseVectorM(b, 10000)
+}
+
+func benchmarkCompressedSparseVectorM(b *testing.B, m int) {
+	// Generate 2 sparse vectors using 75% of numbers 1-m
+	v1 := NewCompressedSparseVector(genRandomSparseVector(m))
+	v2 := NewCompressedSparseVector(genRandomSparseVector(m))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var total Value
+	for i := 0; i < b.N; i++ {
+		result := v1.Cos(v2)
+		total += result
+	}
+}
+
+// genSpreadSparseVector generates a vector of l entries with indices spread
+// randomly over a range of m
+func genSpreadSparseVector(rnd *rand.Rand, l, m int) *SparseVectorUint32 {
+	indices := make([]uint32, l)
+	values := make([]Value, l)
+	for i, v := range rnd.Perm(m)[:l] {
+		indices[i] = uint32(v)
+		values[i] = Value(rnd.Intn(100)) / 4
+	}
+	return NewSparseVectorUint32(indices, values)
+}
+
+func TestCompressedSparseVectorRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	tests := []*SparseVectorUint32{
+		NewSparseVectorUint32([]uint32{}, []Value{}),
+		NewSparseVectorUint32([]uint32{7}, []Value{3}),
+		NewSparseVectorUint32([]uint32{0, 4294967295}, []Value{1, 2}),
+		genSpreadSparseVector(rnd, 128, 1000),
+		genSpreadSparseVector(rnd, 129, 1000),
+		genSpreadSparseVector(rnd, 1000, 1000),
+		genSpreadSparseVector(rnd, 1000, 1000000),
+	}
+
+	for i, test := range tests {
+		c := NewCompressedSparseVector(test)
+		if c.Len() != len(test.indices) {
+			t.Errorf("Test %d. Len is %d", i, c.Len())
+		}
+		d := c.Decompress()
+		if !reflect.DeepEqual(test.indices, d.indices) || !reflect.DeepEqual(test.values, d.values) {
+			t.Errorf("Test %d. Decompressed vector not as expected", i)
+		}
+
+		var indices []uint32
+		c.Iter(func(index uint32, value Value) {
+			indices = append(indices, index)
+		})
+		if len(indices) > 0 && !reflect.DeepEqual(test.indices, indices) {
+			t.Errorf("Test %d. Iter indices not as expected", i)
+		}
+	}
+}
+
+func TestCompressedSparseVectorDot(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	for i := 0; i < 50; i++ {
+		// Mix short and long vectors, and dense and sparse ranges, so we get
+		// both skipped and overlapping blocks
+		sv1 := genSpreadSparseVector(rnd, 1+rnd.Intn(1000), 2000+rnd.Intn(100000))
+		sv2 := genSpreadSparseVector(rnd, 1+rnd.Intn(1000), 2000+rnd.Intn(100000))
+		c1 := NewCompressedSparseVector(sv1)
+		c2 := NewCompressedSparseVector(sv2)
+
+		exp := sv1.Dot(sv2)
+		if dp := c1.Dot(c2); dp != exp {
+			t.Errorf("Test %d. Compressed dot is %f, expected %f", i, dp, exp)
+		}
+		if dp := c1.Dot(sv2); dp != exp {
+			t.Errorf("Test %d. Mixed dot is %f, expected %f", i, dp, exp)
+		}
+		if dp := sv1.Dot(c2); dp != exp {
+			t.Errorf("Test %d. Reverse mixed dot is %f, expected %f", i, dp, exp)
+		}
+		if mag := c1.Mag(); mag != sv1.Mag() {
+			t.Errorf("Test %d. Mag is %f, expected %f", i, mag, sv1.Mag())
+		}
+		if cos, exp := c1.Cos(c2), sv1.Cos(sv2); cos != exp {
+			t.Errorf("Test %d. Cos is %f, expected %f", i, cos, exp)
+		}
+	}
+}
+
+func TestQuantizedCompressedSparseVector(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	sv1 := genSpreadSparseVector(rnd, 500, 1000)
+	sv2 := genSpreadSparseVector(rnd, 500, 1000)
+	q1 := NewQuantizedCompressedSparseVector(sv1)
+	q2 := NewQuantizedCompressedSparseVector(sv2)
+
+	// Values are multiples of 0.25 between 0 and 24.75, so quantize to within
+	// half a step of 24.75/255
+	d := q1.Decompress()
+	for i, v := range d.values {
+		if math.Abs(float64(v-sv1.values[i])) > 24.75/255/2+1e-5 {
+			t.Fatalf("value %d quantized to %f, was %f", i, v, sv1.values[i])
+		}
+	}
+
+	if cos, exp := q1.Cos(q2), sv1.Cos(sv2); math.Abs(float64(cos-exp)) > 0.01 {
+		t.Errorf("Cos is %f, expected about %f", cos, exp)
+	}
+
+	q1.Mult(2)
+	if mag, exp := q1.Mag(), 2*d.Mag(); math.Abs(float64(mag-exp)) > 1e-3*float64(exp) {
+		t.Errorf("Mag after Mult is %f, expected %f", mag, exp)
+	}
+
+	// All values the same
+	c := NewQuantizedCompressedSparseVector(NewSparseVectorUint32([]uint32{1, 2}, []Value{3, 3}))
+	if !reflect.DeepEqual([]Value{3, 3}, c.Decompress().values) {
+		t.Errorf("constant values not as expected. Have %v", c.Decompress().values)
+	}
+}
+
+func TestAddCompressedSparseVector(t *testing.T) {
+	c1 := NewCompressedSparseVector(NewSparseVectorUint32([]uint32{1, 2, 3}, []Value{4, 5, 6}))
+	c2 := NewCompressedSparseVector(NewSparseVectorUint32([]uint32{1, 3, 4}, []Value{4, 5, 6}))
+
+	sum := c1.Add(c2).(*CompressedSparseVector).Decompress()
+	if !reflect.DeepEqual(NewSparseVectorUint32([]uint32{1, 2, 3, 4}, []Value{8, 5, 11, 6}), sum) {
+		t.Errorf("Sum not as expected. Have %v", sum)
+	}
+
+	diff := c1.Sub(NewSparseVectorUint32([]uint32{1, 3, 4}, []Value{4, 5, 6})).(*CompressedSparseVector).Decompress()
+	if !reflect.DeepEqual(NewSparseVectorUint32([]uint32{1, 2, 3, 4}, []Value{0, 5, 1, -6}), diff) {
+		t.Errorf("Difference not as expected. Have %v", diff)
+	}
+
+	c1.Mult(2)
+	if !reflect.DeepEqual([]Value{8, 10, 12}, c1.Decompress().values) {
+		t.Errorf("Mult not as expected. Have %v", c1.Decompress().values)
+	}
+}
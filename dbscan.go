@@ -0,0 +1,188 @@
+package sparsevector
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// Metric selects a distance for clustering algorithms that can use an
+// InvertedIndex to find neighbours
+type Metric byte
+
+const (
+	// CosineMetric is the cosine distance, as CosineDistance
+	CosineMetric Metric = iota
+	// EuclideanMetric is the Euclidean distance, as EuclideanDistance
+	EuclideanMetric
+)
+
+// Noise is the label DBSCAN gives to vectors that are in no cluster
+const Noise = -1
+
+// DBSCAN clusters vectors by density. A vector with at least minPts vectors
+// (including itself) within eps of it is a core vector. Core vectors within
+// eps of each other are in the same cluster, along with every vector within
+// eps of one of its core vectors. Other vectors are noise.
+//
+// It returns a cluster label for each vector, numbered from 0 in the order
+// clusters are found, or Noise.
+//
+// Neighbours are found with an InvertedIndex, so only vectors that share an
+// index are compared, unless eps is large enough for vectors with nothing in
+// common to be neighbours. Distances are calculated from the dot products
+// and magnitudes, so may differ very slightly from CosineDistance and
+// EuclideanDistance. Use DBSCANFunc for other distances.
+func DBSCAN(vectors []*SparseVectorUint32, eps Value, minPts int, metric Metric) ([]int, error) {
+	if metric > EuclideanMetric {
+		return nil, errors.New("sparsevector: unknown metric")
+	}
+	return dbscan(newIndexNeighbourhoods(vectors, eps, metric), minPts), nil
+}
+
+// DBSCANFunc is DBSCAN with any distance. It calculates the distance between
+// every pair of vectors, so is much slower than DBSCAN.
+func DBSCANFunc(vectors []*SparseVectorUint32, eps Value, minPts int, distance DistanceFunc) ([]int, error) {
+	if distance == nil {
+		return nil, errors.New("sparsevector: DBSCANFunc needs a distance function")
+	}
+	return dbscan(&neighbourhoods{vectors: vectors, eps: eps, distance: distance}, minPts), nil
+}
+
+func dbscan(n *neighbourhoods, minPts int) []int {
+	vectors := n.vectors
+	const unvisited = -2
+	labels := make([]int, len(vectors))
+	for i := range labels {
+		labels[i] = unvisited
+	}
+
+	cluster := 0
+	for i := range vectors {
+		if labels[i] != unvisited {
+			continue
+		}
+		neighbours := n.neighbours(i)
+		if len(neighbours) < minPts {
+			labels[i] = Noise
+			continue
+		}
+
+		// Expand the cluster from this core vector
+		labels[i] = cluster
+		queue := neighbours
+		for len(queue) > 0 {
+			j := queue[0]
+			queue = queue[1:]
+			if labels[j] == Noise {
+				// A border vector
+				labels[j] = cluster
+			}
+			if labels[j] != unvisited {
+				continue
+			}
+			labels[j] = cluster
+			if jn := n.neighbours(j); len(jn) >= minPts {
+				queue = append(queue, jn...)
+			}
+		}
+		cluster++
+	}
+	return labels
+}
+
+// neighbourhoods finds the vectors within eps of each vector. If ix is set
+// neighbours are found through it using metric, otherwise distance is
+// calculated to every vector.
+type neighbourhoods struct {
+	vectors  []*SparseVectorUint32
+	eps      Value
+	distance DistanceFunc
+	metric   Metric
+	ix       *InvertedIndex
+	// byMag orders the vectors by magnitude
+	byMag []int
+}
+
+func newIndexNeighbourhoods(vectors []*SparseVectorUint32, eps Value, metric Metric) *neighbourhoods {
+	n := &neighbourhoods{
+		vectors: vectors,
+		eps:     eps,
+		metric:  metric,
+		ix:      NewInvertedIndex(),
+		byMag:   make([]int, len(vectors)),
+	}
+	for i, sv := range vectors {
+		n.ix.Add(sv)
+		n.byMag[i] = i
+	}
+	sort.SliceStable(n.byMag, func(i, j int) bool {
+		return vectors[n.byMag[i]].Mag() < vectors[n.byMag[j]].Mag()
+	})
+	return n
+}
+
+// dotDistance calculates the distance between vectors i and j from their dot
+// product
+func (n *neighbourhoods) dotDistance(i, j int, dot Value) Value {
+	mi, mj := float64(n.vectors[i].Mag()), float64(n.vectors[j].Mag())
+	if n.metric == CosineMetric {
+		if mi == 0 || mj == 0 {
+			return 1
+		}
+		return Value(1 - float64(dot)/(mi*mj))
+	}
+	return Value(math.Sqrt(math.Max(0, mi*mi+mj*mj-2*float64(dot))))
+}
+
+// neighbours returns the vectors within eps of vector i, including i
+func (n *neighbourhoods) neighbours(i int) []int {
+	var out []int
+	if n.ix == nil {
+		for j, sv := range n.vectors {
+			if j == i || n.distance(n.vectors[i], sv) <= n.eps {
+				out = append(out, j)
+			}
+		}
+		return out
+	}
+
+	ids, dots := n.ix.Dots(n.vectors[i])
+	shared := make(map[int]bool, len(ids))
+	for k, j := range ids {
+		shared[j] = true
+		if j == i || n.dotDistance(i, j, dots[k]) <= n.eps {
+			out = append(out, j)
+		}
+	}
+	if !shared[i] {
+		// A vector with no entries still neighbours itself
+		out = append(out, i)
+		shared[i] = true
+	}
+
+	// Vectors sharing no index have a dot product of zero
+	switch n.metric {
+	case CosineMetric:
+		if n.eps < 1 {
+			return out
+		}
+		for j := range n.vectors {
+			if !shared[j] {
+				out = append(out, j)
+			}
+		}
+	case EuclideanMetric:
+		// The distance is sqrt(|i|^2 + |j|^2), so only the smallest vectors
+		// can be close enough
+		for _, j := range n.byMag {
+			if n.dotDistance(i, j, 0) > n.eps {
+				break
+			}
+			if !shared[j] {
+				out = append(out, j)
+			}
+		}
+	}
+	return out
+}
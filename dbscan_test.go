@@ -0,0 +1,132 @@
+package sparsevector
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// naiveDBSCAN is DBSCAN comparing every pair of vectors
+func naiveDBSCAN(vectors []*SparseVectorUint32, eps Value, minPts int, distance DistanceFunc) []int {
+	neighbours := func(i int) []int {
+		var out []int
+		for j := range vectors {
+			if i == j || distance(vectors[i], vectors[j]) <= eps {
+				out = append(out, j)
+			}
+		}
+		return out
+	}
+
+	labels := make([]int, len(vectors))
+	for i := range labels {
+		labels[i] = -2
+	}
+	cluster := 0
+	for i := range vectors {
+		if labels[i] != -2 {
+			continue
+		}
+		queue := neighbours(i)
+		if len(queue) < minPts {
+			labels[i] = Noise
+			continue
+		}
+		labels[i] = cluster
+		for len(queue) > 0 {
+			j := queue[0]
+			queue = queue[1:]
+			if labels[j] == Noise {
+				labels[j] = cluster
+			}
+			if labels[j] != -2 {
+				continue
+			}
+			labels[j] = cluster
+			if jn := neighbours(j); len(jn) >= minPts {
+				queue = append(queue, jn...)
+			}
+		}
+		cluster++
+	}
+	return labels
+}
+
+func TestDBSCAN(t *testing.T) {
+	points := []*SparseVectorUint32{
+		point(1, 1), point(1.5, 1), point(1, 1.5), point(2.2, 1),
+		point(10, 10), point(10.5, 10), point(10, 10.5),
+		point(5, 5),
+	}
+	labels, err := DBSCAN(points, 0.75, 3, EuclideanMetric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Point 3 is a border point, point 7 noise
+	if exp := []int{0, 0, 0, 0, 1, 1, 1, Noise}; !reflect.DeepEqual(exp, labels) {
+		t.Errorf("Labels are %v, expected %v", labels, exp)
+	}
+
+	if _, err := DBSCAN(points, 1, 1, Metric(5)); err == nil {
+		t.Errorf("expected an error for an unknown metric")
+	}
+	if _, err := DBSCANFunc(points, 1, 1, nil); err == nil {
+		t.Errorf("expected an error with no distance function")
+	}
+}
+
+func TestDBSCANRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	vectors, _ := genClusterTestVectors(rnd, 3, 30)
+	// Small vectors, with nothing in common with the rest
+	for i := 0; i < 5; i++ {
+		vectors = append(vectors, NewSparseVectorUint32([]uint32{uint32(5000 + i)}, []Value{0.1}))
+	}
+	vectors = append(vectors, NewSparseVectorUint32([]uint32{}, []Value{}))
+
+	manhattan := func(a, b *SparseVectorUint32) Value {
+		var d Value
+		a.Sub(b).(*SparseVectorUint32).Iter(func(index uint32, value Value) {
+			if value < 0 {
+				value = -value
+			}
+			d += value
+		})
+		return d
+	}
+
+	tests := []struct {
+		eps    Value
+		minPts int
+		metric Metric
+		// distance is the same as metric, or any distance if metric is not valid
+		distance DistanceFunc
+	}{
+		{0.85, 3, CosineMetric, CosineDistance},
+		{0.8, 2, CosineMetric, CosineDistance},
+		{1, 3, CosineMetric, CosineDistance},
+		{19.5, 3, EuclideanMetric, EuclideanDistance},
+		{0.5, 2, EuclideanMetric, EuclideanDistance},
+		{90, 3, Metric(255), manhattan},
+		{80, 3, Metric(255), manhattan},
+	}
+	for i, test := range tests {
+		exp := naiveDBSCAN(vectors, test.eps, test.minPts, test.distance)
+		labels, err := DBSCANFunc(vectors, test.eps, test.minPts, test.distance)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(exp, labels) {
+			t.Errorf("Test %d. DBSCANFunc labels are %v, expected %v", i, labels, exp)
+		}
+		if test.metric > EuclideanMetric {
+			continue
+		}
+		if labels, err = DBSCAN(vectors, test.eps, test.minPts, test.metric); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(exp, labels) {
+			t.Errorf("Test %d. Labels are %v, expected %v", i, labels, exp)
+		}
+	}
+}
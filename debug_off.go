@@ -0,0 +1,5 @@
+//go:build !sparsevectordebug
+
+package sparsevector
+
+const debugChecks = false
@@ -0,0 +1,8 @@
+//go:build sparsevectordebug
+
+package sparsevector
+
+// debugChecks turns on checking of vector invariants in constructors and
+// methods that modify vectors. Build with the sparsevectordebug tag to enable
+// it.
+const debugChecks = true
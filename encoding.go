@@ -0,0 +1,340 @@
+package sparsevector
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The binary encoding of a vector is a two byte header giving the format
+// version and the kind of index, followed by the number of entries as a
+// uvarint, the indices, and then the values as little-endian float32s.
+//
+// uint32 indices are delta encoded as uvarints. As indices are strictly
+// increasing every delta after the first must be at least 1. int indices are
+// the same except the first index is a zig-zag varint. String indices are
+// each a uvarint length followed by the bytes of the string.
+const (
+	binaryVersion byte = 1
+
+	binaryKindUint32 byte = 1
+	binaryKindInt    byte = 2
+	binaryKindString byte = 3
+)
+
+// ErrUnsupportedIndex is returned when asked to encode or decode a
+// GenSparseVector whose VectorIndex has no binary encoding.
+var ErrUnsupportedIndex = errors.New("sparsevector: unsupported index type")
+
+// MarshalBinary encodes the vector. Indices must be strictly increasing.
+func (sv *SparseVectorUint32) MarshalBinary() ([]byte, error) {
+	return appendUint32Vector(nil, sv.indices, sv.values)
+}
+
+// UnmarshalBinary decodes a vector encoded with MarshalBinary. It replaces
+// the contents of sv.
+func (sv *SparseVectorUint32) UnmarshalBinary(data []byte) error {
+	kind, data, err := readBinaryHeader(data)
+	if err != nil {
+		return err
+	}
+	if kind != binaryKindUint32 {
+		return fmt.Errorf("sparsevector: cannot decode index kind %d into SparseVectorUint32", kind)
+	}
+	indices, values, err := readUint32Vector(data)
+	if err != nil {
+		return err
+	}
+	*sv = SparseVectorUint32{indices: indices, values: values}
+	return nil
+}
+
+// MarshalBinary encodes the vector. The encoding is the same as for
+// SparseVectorUint32, so either type can decode the other.
+func (m *MapSparseVector) MarshalBinary() ([]byte, error) {
+	indices, values := m.sorted()
+	return appendUint32Vector(nil, indices, values)
+}
+
+// UnmarshalBinary decodes a vector encoded with MarshalBinary. It replaces
+// the contents of m.
+func (m *MapSparseVector) UnmarshalBinary(data []byte) error {
+	var sv SparseVectorUint32
+	if err := sv.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	*m = *NewMapSparseVector(sv.indices, sv.values)
+	return nil
+}
+
+// MarshalBinary encodes the vector. The index must be a Uint32Index, IntIndex
+// or StringIndex, and must be strictly increasing.
+func (sv *GenSparseVector) MarshalBinary() ([]byte, error) {
+	switch index := sv.index.(type) {
+	case Uint32Index:
+		return appendUint32Vector(nil, index, sv.values)
+	case IntIndex:
+		return appendIntVector(nil, index, sv.values)
+	case StringIndex:
+		return appendStringVector(nil, index, sv.values)
+	}
+	return nil, ErrUnsupportedIndex
+}
+
+// UnmarshalBinary decodes a vector encoded with MarshalBinary. The type of
+// the index is taken from the encoded data. Data encoded from a
+// SparseVectorUint32 or MapSparseVector decodes with a Uint32Index.
+func (sv *GenSparseVector) UnmarshalBinary(data []byte) error {
+	kind, data, err := readBinaryHeader(data)
+	if err != nil {
+		return err
+	}
+
+	var index VectorIndex
+	var values []Value
+	switch kind {
+	case binaryKindUint32:
+		var indices []uint32
+		indices, values, err = readUint32Vector(data)
+		index = Uint32Index(indices)
+	case binaryKindInt:
+		index, values, err = readIntVector(data)
+	case binaryKindString:
+		index, values, err = readStringVector(data)
+	default:
+		return ErrUnsupportedIndex
+	}
+	if err != nil {
+		return err
+	}
+	*sv = GenSparseVector{index: index, values: values}
+	return nil
+}
+
+func appendBinaryHeader(buf []byte, kind byte, l int) []byte {
+	buf = append(buf, binaryVersion, kind)
+	return binary.AppendUvarint(buf, uint64(l))
+}
+
+func appendUint32Vector(buf []byte, indices []uint32, values []Value) ([]byte, error) {
+	if len(indices) != len(values) {
+		return nil, fmt.Errorf("sparsevector: %d indices but %d values", len(indices), len(values))
+	}
+	buf = appendBinaryHeader(buf, binaryKindUint32, len(indices))
+	var prev uint32
+	for i, idx := range indices {
+		if i > 0 && idx <= prev {
+			return nil, fmt.Errorf("sparsevector: index %d at position %d is not greater than previous index %d", idx, i, prev)
+		}
+		buf = binary.AppendUvarint(buf, uint64(idx-prev))
+		prev = idx
+	}
+	return appendValues(buf, values), nil
+}
+
+func appendIntVector(buf []byte, indices IntIndex, values []Value) ([]byte, error) {
+	if len(indices) != len(values) {
+		return nil, fmt.Errorf("sparsevector: %d indices but %d values", len(indices), len(values))
+	}
+	buf = appendBinaryHeader(buf, binaryKindInt, len(indices))
+	for i, idx := range indices {
+		if i == 0 {
+			buf = binary.AppendVarint(buf, int64(idx))
+			continue
+		}
+		prev := indices[i-1]
+		if idx <= prev {
+			return nil, fmt.Errorf("sparsevector: index %d at position %d is not greater than previous index %d", idx, i, prev)
+		}
+		buf = binary.AppendUvarint(buf, uint64(idx)-uint64(prev))
+	}
+	return appendValues(buf, values), nil
+}
+
+func appendStringVector(buf []byte, indices StringIndex, values []Value) ([]byte, error) {
+	if len(indices) != len(values) {
+		return nil, fmt.Errorf("sparsevector: %d indices but %d values", len(indices), len(values))
+	}
+	buf = appendBinaryHeader(buf, binaryKindString, len(indices))
+	for i, idx := range indices {
+		if i > 0 && idx <= indices[i-1] {
+			return nil, fmt.Errorf("sparsevector: index %q at position %d is not greater than previous index %q", idx, i, indices[i-1])
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(idx)))
+		buf = append(buf, idx...)
+	}
+	return appendValues(buf, values), nil
+}
+
+func appendValues(buf []byte, values []Value) []byte {
+	for _, v := range values {
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(v)))
+	}
+	return buf
+}
+
+// readBinaryHeader checks the version and returns the index kind and the
+// remaining data
+func readBinaryHeader(data []byte) (byte, []byte, error) {
+	if len(data) < 2 {
+		return 0, nil, errors.New("sparsevector: encoded vector too short")
+	}
+	if data[0] != binaryVersion {
+		return 0, nil, fmt.Errorf("sparsevector: unsupported encoding version %d", data[0])
+	}
+	return data[1], data[2:], nil
+}
+
+// readLength reads the number of entries in the vector. Every entry takes at
+// least one byte of index and four of value, so we can reject lengths the data
+// cannot possibly hold before we allocate.
+func readLength(data []byte) (int, []byte, error) {
+	l, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, errors.New("sparsevector: bad vector length")
+	}
+	data = data[n:]
+	if l > uint64(len(data)/5) {
+		return 0, nil, fmt.Errorf("sparsevector: vector length %d too long for %d bytes of data", l, len(data))
+	}
+	return int(l), data, nil
+}
+
+func readUint32Vector(data []byte) ([]uint32, []Value, error) {
+	l, data, err := readLength(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	indices := make([]uint32, l)
+	var prev uint64
+	for i := range indices {
+		delta, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, nil, fmt.Errorf("sparsevector: bad index at position %d", i)
+		}
+		data = data[n:]
+		if i > 0 && delta == 0 {
+			return nil, nil, fmt.Errorf("sparsevector: index at position %d is not greater than previous index %d", i, prev)
+		}
+		prev += delta
+		if prev > math.MaxUint32 {
+			return nil, nil, fmt.Errorf("sparsevector: index at position %d overflows uint32", i)
+		}
+		indices[i] = uint32(prev)
+	}
+	values, err := readValues(data, l)
+	if err != nil {
+		return nil, nil, err
+	}
+	return indices, values, nil
+}
+
+func readIntVector(data []byte) (IntIndex, []Value, error) {
+	l, data, err := readLength(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	indices := make(IntIndex, l)
+	for i := range indices {
+		if i == 0 {
+			first, n := binary.Varint(data)
+			if n <= 0 || int64(int(first)) != first {
+				return nil, nil, errors.New("sparsevector: bad index at position 0")
+			}
+			data = data[n:]
+			indices[0] = int(first)
+			continue
+		}
+		delta, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, nil, fmt.Errorf("sparsevector: bad index at position %d", i)
+		}
+		data = data[n:]
+		prev := indices[i-1]
+		if delta == 0 {
+			return nil, nil, fmt.Errorf("sparsevector: index at position %d is not greater than previous index %d", i, prev)
+		}
+		if delta > uint64(math.MaxInt)-uint64(prev) {
+			return nil, nil, fmt.Errorf("sparsevector: index at position %d overflows int", i)
+		}
+		indices[i] = prev + int(delta)
+	}
+	values, err := readValues(data, l)
+	if err != nil {
+		return nil, nil, err
+	}
+	return indices, values, nil
+}
+
+func readStringVector(data []byte) (StringIndex, []Value, error) {
+	l, data, err := readLength(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	indices := make(StringIndex, l)
+	for i := range indices {
+		sl, n := binary.Uvarint(data)
+		if n <= 0 || sl > uint64(len(data)-n) {
+			return nil, nil, fmt.Errorf("sparsevector: bad index at position %d", i)
+		}
+		indices[i] = string(data[n : n+int(sl)])
+		data = data[n+int(sl):]
+		if i > 0 && indices[i] <= indices[i-1] {
+			return nil, nil, fmt.Errorf("sparsevector: index %q at position %d is not greater than previous index %q", indices[i], i, indices[i-1])
+		}
+	}
+	values, err := readValues(data, l)
+	if err != nil {
+		return nil, nil, err
+	}
+	return indices, values, nil
+}
+
+func readValues(data []byte, l int) ([]Value, error) {
+	if len(data) != l*4 {
+		return nil, fmt.Errorf("sparsevector: have %d bytes of values, expected %d", len(data), l*4)
+	}
+	values := make([]Value, l)
+	for i := range values {
+		values[i] = Value(math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:])))
+	}
+	return values, nil
+}
+
+// Assert our vectors implement the binary encoding interfaces
+var (
+	_ encoding.BinaryMarshaler   = (*SparseVectorUint32)(nil)
+	_ encoding.BinaryUnmarshaler = (*SparseVectorUint32)(nil)
+	_ encoding.BinaryMarshaler   = (*MapSparseVector)(nil)
+	_ encoding.BinaryUnmarshaler = (*MapSparseVector)(nil)
+	_ encoding.BinaryMarshaler   = (*GenSparseVector)(nil)
+	_ encoding.BinaryUnmarshaler = (*GenSparseVector)(nil)
+)
+
+// appendEmbeddedVector appends the encoding of a vector prefixed by its
+// length, so it can be embedded in a larger encoding
+func appendEmbeddedVector(buf []byte, sv *SparseVectorUint32) ([]byte, error) {
+	data, err := sv.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...), nil
+}
+
+// readEmbeddedVector reads a vector encoded by appendEmbeddedVector, and
+// returns the data that follows it
+func readEmbeddedVector(data []byte) (*SparseVectorUint32, []byte, error) {
+	l, n := binary.Uvarint(data)
+	if n <= 0 || l > uint64(len(data)-n) {
+		return nil, nil, errors.New("sparsevector: bad embedded vector length")
+	}
+	data = data[n:]
+	sv := &SparseVectorUint32{}
+	if err := sv.UnmarshalBinary(data[:l]); err != nil {
+		return nil, nil, err
+	}
+	return sv, data[l:], nil
+}
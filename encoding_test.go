@@ -0,0 +1,144 @@
+package sparsevector
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestBinarySparseVectorUint32(t *testing.T) {
+	tests := []*SparseVectorUint32{
+		NewSparseVectorUint32([]uint32{}, []Value{}),
+		NewSparseVectorUint32([]uint32{0}, []Value{1}),
+		NewSparseVectorUint32([]uint32{1, 3, 4}, []Value{4, 5, 6}),
+		NewSparseVectorUint32([]uint32{7, 300, 70000, 4294967295}, []Value{-1.5, 0, 3.25, 1e30}),
+	}
+
+	for i, test := range tests {
+		data, err := test.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Test %d. Marshal failed. %v", i, err)
+		}
+		var sv SparseVectorUint32
+		if err := sv.UnmarshalBinary(data); err != nil {
+			t.Fatalf("Test %d. Unmarshal failed. %v", i, err)
+		}
+		if !reflect.DeepEqual(test.indices, sv.indices) || !reflect.DeepEqual(test.values, sv.values) {
+			t.Errorf("Test %d. Round trip not as expected. Have %v", i, sv)
+		}
+	}
+}
+
+func TestBinaryDeltaEncoding(t *testing.T) {
+	sv := NewSparseVectorUint32([]uint32{100, 101, 228}, []Value{1, 2, 3})
+	data, err := sv.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// header, length, 100, 1, 127, then 3 float32s
+	exp := []byte{1, 1, 3, 100, 1, 127}
+	if !reflect.DeepEqual(exp, data[:len(exp)]) {
+		t.Errorf("encoding not as expected. Have %v", data[:len(exp)])
+	}
+	if len(data) != len(exp)+12 {
+		t.Errorf("encoded length not as expected. Have %d", len(data))
+	}
+}
+
+func TestBinaryMapSparseVector(t *testing.T) {
+	m := NewMapSparseVector([]uint32{4, 1, 3}, []Value{6, 4, 5})
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m2 MapSparseVector
+	if err := m2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, &m2) {
+		t.Errorf("Round trip not as expected. Have %v", m2)
+	}
+
+	// The encoding is shared with SparseVectorUint32
+	var sv SparseVectorUint32
+	if err := sv.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(NewSparseVectorUint32([]uint32{1, 3, 4}, []Value{4, 5, 6}), &sv) {
+		t.Errorf("Decode as SparseVectorUint32 not as expected. Have %v", sv)
+	}
+}
+
+func TestBinaryGenSparseVector(t *testing.T) {
+	tests := []*GenSparseVector{
+		NewGenSparseVector(Uint32Index{1, 3, 4}, []Value{4, 5, 6}),
+		NewGenSparseVector(IntIndex{-1000, -3, 0, 4, math.MaxInt}, []Value{1, 2, 3, 4, 5}),
+		NewGenSparseVector(StringIndex{"b", "a", "", "héllo"}, []Value{1, 2, 3, 4}),
+		NewGenSparseVector(StringIndex{}, []Value{}),
+	}
+
+	for i, test := range tests {
+		data, err := test.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Test %d. Marshal failed. %v", i, err)
+		}
+		var sv GenSparseVector
+		if err := sv.UnmarshalBinary(data); err != nil {
+			t.Fatalf("Test %d. Unmarshal failed. %v", i, err)
+		}
+		if !reflect.DeepEqual(test.index, sv.index) || !reflect.DeepEqual(test.values, sv.values) {
+			t.Errorf("Test %d. Round trip not as expected. Have %v", i, sv)
+		}
+	}
+}
+
+func TestBinaryMarshalErrors(t *testing.T) {
+	tests := []interface {
+		MarshalBinary() ([]byte, error)
+	}{
+		&SparseVectorUint32{indices: []uint32{1, 1}, values: []Value{1, 2}},
+		&SparseVectorUint32{indices: []uint32{1, 2}, values: []Value{1}},
+		&SparseVectorUint32{indices: []uint32{2, 1}, values: []Value{1, 2}},
+		&GenSparseVector{index: IntIndex{3, 3}, values: []Value{1, 2}},
+		&GenSparseVector{index: StringIndex{"a", "a"}, values: []Value{1, 2}},
+	}
+
+	for i, test := range tests {
+		if _, err := test.MarshalBinary(); err == nil {
+			t.Errorf("Test %d. Expected an error", i)
+		}
+	}
+}
+
+func TestBinaryUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: []byte{}},
+		{name: "version", data: []byte{2, 1, 0}},
+		{name: "kind", data: []byte{1, 2, 0}},
+		{name: "no length", data: []byte{1, 1}},
+		{name: "length too long", data: []byte{1, 1, 2, 1, 0, 0, 0, 0}},
+		{name: "not increasing", data: []byte{1, 1, 2, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{name: "overflow", data: []byte{1, 1, 2, 0xff, 0xff, 0xff, 0xff, 0x0f, 1, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{name: "trailing", data: []byte{1, 1, 1, 1, 0, 0, 0, 0, 0}},
+		{name: "short values", data: []byte{1, 1, 1, 1, 0, 0, 0, 0, 0}[:7]},
+	}
+
+	for _, test := range tests {
+		var sv SparseVectorUint32
+		if err := sv.UnmarshalBinary(test.data); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+
+	var sv GenSparseVector
+	if err := sv.UnmarshalBinary([]byte{1, 3, 2, 1, 'b', 1, 'a', 0, 0, 0, 0, 0, 0, 0, 0}); err == nil {
+		t.Errorf("expected an error for unordered strings")
+	}
+	if err := sv.UnmarshalBinary([]byte{1, 9, 0}); err != ErrUnsupportedIndex {
+		t.Errorf("expected ErrUnsupportedIndex, have %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package sparsevector
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"sort"
+)
+
+// FeatureHasher turns features into a SparseVectorUint32 using the hashing
+// trick. Each feature name is hashed to an index in a space of 2^n indices, so
+// no vocabulary is needed. A second hash decides whether the feature's value
+// is added or subtracted, so that collisions tend to cancel out rather than
+// accumulate.
+//
+// Hashing uses 32-bit MurmurHash3 seeded with the seed given to
+// NewFeatureHasher, so hashers with the same bits and seed always give a
+// feature the same index, in any process.
+type FeatureHasher struct {
+	mask uint32
+	seed uint32
+}
+
+// NewFeatureHasher creates a FeatureHasher producing indices below 2^bits.
+// bits must be between 1 and 32. Hashers with different seeds produce
+// unrelated indices.
+func NewFeatureHasher(bits uint, seed uint32) *FeatureHasher {
+	if bits < 1 || bits > 32 {
+		panic("sparsevector: FeatureHasher bits must be between 1 and 32")
+	}
+	return &FeatureHasher{
+		mask: uint32(1<<bits - 1),
+		seed: seed,
+	}
+}
+
+// Index returns the index a feature hashes to, and the sign (1 or -1) its
+// value is multiplied by.
+func (h *FeatureHasher) Index(feature string) (uint32, Value) {
+	index := murmur3(feature, h.seed) & h.mask
+	// Use a different seed for the sign so it is independent of the index
+	if murmur3(feature, ^h.seed)&1 == 0 {
+		return index, 1
+	}
+	return index, -1
+}
+
+// HashTokens creates a vector counting the tokens. Each token adds 1 (or -1,
+// depending on the sign hash) at its index.
+func (h *FeatureHasher) HashTokens(tokens []string) *SparseVectorUint32 {
+	indices := make([]uint32, len(tokens))
+	values := make([]Value, len(tokens))
+	for i, token := range tokens {
+		indices[i], values[i] = h.Index(token)
+	}
+	return h.build(indices, values)
+}
+
+// HashFeatures creates a vector from parallel arrays of feature names and
+// values. Values for features that hash to the same index are added together.
+func (h *FeatureHasher) HashFeatures(features []string, values []Value) *SparseVectorUint32 {
+	indices := make([]uint32, len(features))
+	hvalues := make([]Value, len(features))
+	for i, feature := range features {
+		var sign Value
+		indices[i], sign = h.Index(feature)
+		hvalues[i] = sign * values[i]
+	}
+	return h.build(indices, hvalues)
+}
+
+// build sorts the entries, merges duplicates and drops any that have
+// cancelled out
+func (h *FeatureHasher) build(indices []uint32, values []Value) *SparseVectorUint32 {
+	// Not NewSparseVectorUint32, as the vector isn't valid until duplicates
+	// are merged
+	sv := &SparseVectorUint32{indices: indices, values: values}
+	sort.Sort(sparseVectorUint32Sort{sv})
+	sv.mergeDuplicates()
+	sv.dropZeros()
+	return sv
+}
+
+// murmur3 is the 32-bit MurmurHash3 of s
+func murmur3(s string, seed uint32) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+	h := seed
+	data := []byte(s)
+	for len(data) >= 4 {
+		k := binary.LittleEndian.Uint32(data)
+		data = data[4:]
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k uint32
+	switch len(data) {
+	case 3:
+		k ^= uint32(data[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(data[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(data[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(s))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
@@ -0,0 +1,96 @@
+package sparsevector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMurmur3(t *testing.T) {
+	tests := []struct {
+		s    string
+		seed uint32
+		exp  uint32
+	}{
+		{"", 0, 0},
+		{"", 1, 0x514e28b7},
+		{"test", 0, 0xba6bd213},
+		{"Hello, world!", 0, 0xc0363e43},
+		{"Hello, world!", 0x9747b28c, 0x24884cba},
+		{"The quick brown fox jumps over the lazy dog", 0, 0x2e4ff723},
+	}
+	for i, test := range tests {
+		if h := murmur3(test.s, test.seed); h != test.exp {
+			t.Errorf("Test %d. Hash of %q is %x, expected %x", i, test.s, h, test.exp)
+		}
+	}
+}
+
+func TestFeatureHasher(t *testing.T) {
+	h := NewFeatureHasher(4, 42)
+	tokens := []string{"a", "b", "c", "a", "d", "e", "f", "g", "h", "a"}
+
+	// Build the expected vector from Index
+	exp := make(map[uint32]Value)
+	for _, token := range tokens {
+		index, sign := h.Index(token)
+		if index >= 16 {
+			t.Fatalf("index %d for %q out of range", index, token)
+		}
+		if sign != 1 && sign != -1 {
+			t.Fatalf("sign %f for %q", sign, token)
+		}
+		exp[index] += sign
+	}
+
+	sv := h.HashTokens(tokens)
+	for i, index := range sv.indices {
+		if i > 0 && index <= sv.indices[i-1] {
+			t.Fatalf("indices not strictly increasing. Have %v", sv.indices)
+		}
+		if sv.values[i] != exp[index] || sv.values[i] == 0 {
+			t.Errorf("value at %d is %f, expected %f", index, sv.values[i], exp[index])
+		}
+		delete(exp, index)
+	}
+	for index, v := range exp {
+		if v != 0 {
+			t.Errorf("index %d missing", index)
+		}
+	}
+
+	// The same seed gives the same result, a different seed a different one
+	if !reflect.DeepEqual(sv, NewFeatureHasher(4, 42).HashTokens(tokens)) {
+		t.Errorf("hashing not deterministic")
+	}
+	if reflect.DeepEqual(sv, NewFeatureHasher(4, 43).HashTokens(tokens)) {
+		t.Errorf("different seeds give the same vector")
+	}
+}
+
+func TestFeatureHasherFeatures(t *testing.T) {
+	h := NewFeatureHasher(20, 1)
+	features := []string{"colour=red", "size", "weight", "size"}
+	values := []Value{1, 2.5, 0.5, 1.5}
+
+	sv := h.HashFeatures(features, values)
+	exp := make(map[uint32]Value)
+	for i, feature := range features {
+		index, sign := h.Index(feature)
+		exp[index] += sign * values[i]
+	}
+	if len(sv.indices) != len(exp) {
+		t.Fatalf("have %d entries, expected %d", len(sv.indices), len(exp))
+	}
+	for i, index := range sv.indices {
+		if sv.values[i] != exp[index] {
+			t.Errorf("value at %d is %f, expected %f", index, sv.values[i], exp[index])
+		}
+	}
+
+	// Full 32 bit space
+	h = NewFeatureHasher(32, 1)
+	index, _ := h.Index("size")
+	if index != murmur3("size", 1) {
+		t.Errorf("32 bit index is %d, expected %d", index, murmur3("size", 1))
+	}
+}
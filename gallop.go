@@ -0,0 +1,80 @@
+package sparsevector
+
+import "sort"
+
+// gallopRatio is how many times longer one vector must be than the other
+// before Dot stops walking through every entry of the long vector, and
+// instead searches it for each index of the short one.
+const gallopRatio = 32
+
+// gallopUint32 returns the first position at or after lo where s[pos] >=
+// target, or len(s) if there is none. It searches exponentially from lo, so
+// it is fast when the answer is close to lo, then binary searches.
+func gallopUint32(s []uint32, lo int, target uint32) int {
+	hi := lo
+	step := 1
+	for hi < len(s) && s[hi] < target {
+		lo = hi + 1
+		hi += step
+		step *= 2
+	}
+	if hi > len(s) {
+		hi = len(s)
+	}
+	return lo + sort.Search(hi-lo, func(i int) bool { return s[lo+i] >= target })
+}
+
+// gallopDotUint32 calculates the dot product of a short vector and a much
+// longer one. Products are summed in index order, the same as the merge in
+// dotUint32, so the result is identical.
+func gallopDotUint32(si []uint32, sv []Value, li []uint32, lv []Value) Value {
+	var dp Value
+	var pos int
+	for i, index := range si {
+		pos = gallopUint32(li, pos, index)
+		if pos == len(li) {
+			break
+		}
+		if li[pos] == index {
+			dp += sv[i] * lv[pos]
+			pos++
+		}
+	}
+	return dp
+}
+
+// gallopIndex is gallopUint32 for a VectorIndex. It returns the first
+// position at or after lo in long whose value is not less than the value at
+// position i in short.
+func gallopIndex(long VectorIndex, lo int, short VectorIndex, i int) int {
+	l := long.Len()
+	hi := lo
+	step := 1
+	for hi < l && long.LessThanOther(hi, short, i) {
+		lo = hi + 1
+		hi += step
+		step *= 2
+	}
+	if hi > l {
+		hi = l
+	}
+	return lo + sort.Search(hi-lo, func(j int) bool { return !long.LessThanOther(lo+j, short, i) })
+}
+
+// gallopDotGen is gallopDotUint32 for GenSparseVectors.
+func gallopDotGen(short, long *GenSparseVector) Value {
+	var dp Value
+	var pos int
+	ll := long.index.Len()
+	for i := range short.values {
+		pos = gallopIndex(long.index, pos, short.index, i)
+		if pos == ll {
+			break
+		}
+		if !short.index.LessThanOther(i, long.index, pos) {
+			dp += short.values[i] * long.values[pos]
+			pos++
+		}
+	}
+	return dp
+}
@@ -0,0 +1,64 @@
+package sparsevector
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func BenchmarkDotSkewed(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	short := genSpreadSparseVector(rnd, 20, 1000000)
+	long := genSpreadSparseVector(rnd, 100000, 1000000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var total Value
+	for i := 0; i < b.N; i++ {
+		total += short.Dot(long)
+	}
+}
+
+func TestGallopUint32(t *testing.T) {
+	s := []uint32{1, 3, 5, 7, 9, 11, 13, 15, 17, 19, 21}
+	for lo := 0; lo <= len(s); lo++ {
+		for target := uint32(0); target < 24; target++ {
+			exp := lo + sort.Search(len(s)-lo, func(i int) bool { return s[lo+i] >= target })
+			if pos := gallopUint32(s, lo, target); pos != exp {
+				t.Errorf("gallop from %d for %d gives %d, expected %d", lo, target, pos, exp)
+			}
+		}
+	}
+}
+
+func TestGallopDot(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+	for i := 0; i < 200; i++ {
+		m := 100 + rnd.Intn(100000)
+		l := 1 + rnd.Intn(m)
+		short := genSpreadSparseVector(rnd, 1+rnd.Intn(1+l/gallopRatio), m)
+		long := genSpreadSparseVector(rnd, l, m)
+		for _, sv := range []*SparseVectorUint32{short, long} {
+			for j := range sv.values {
+				sv.values[j] = Value(rnd.Float32()*100 - 50)
+			}
+		}
+
+		if dp, exp := short.Dot(long), scalarDot(short, long); dp != exp {
+			t.Errorf("Test %d. Dot is %v, expected %v", i, dp, exp)
+		}
+		if dp, exp := long.Dot(short), scalarDot(long, short); dp != exp {
+			t.Errorf("Test %d. Reverse Dot is %v, expected %v", i, dp, exp)
+		}
+
+		gshort := NewGenSparseVector(Uint32Index(short.indices), short.values)
+		glong := NewGenSparseVector(Uint32Index(long.indices), long.values)
+		if dp, exp := gshort.Dot(glong), scalarDot(short, long); dp != exp {
+			t.Errorf("Test %d. GenSparseVector Dot is %v, expected %v", i, dp, exp)
+		}
+		if dp, exp := glong.Dot(gshort), scalarDot(long, short); dp != exp {
+			t.Errorf("Test %d. Reverse GenSparseVector Dot is %v, expected %v", i, dp, exp)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package sparsevector
 
 import (
-	"math"
 	"sort"
 )
 
@@ -12,10 +11,11 @@ import (
 // penalty relative to using a SparseVector. However it is faster than MapSparseVector for
 // the vector lengths we have benchmarked
 type GenSparseVector struct {
-	index    VectorIndex
-	values   []Value
-	mag      Value
-	magClean bool
+	index     VectorIndex
+	values    []Value
+	mag       Value
+	magClean  bool
+	precision Precision
 }
 
 // NewGenSparseVector creates a new GenSparseVector. You should provide parallel arrays of
@@ -26,6 +26,7 @@ func NewGenSparseVector(index VectorIndex, values []Value) *GenSparseVector {
 
 	gsv := genSparseVectorSort{v}
 	sort.Sort(gsv)
+	debugCheck(v)
 	return v
 }
 
@@ -46,11 +47,22 @@ func (v genSparseVectorSort) Less(i, j int) bool { return v.index.Less(i, j) }
 // Both vectors should be GenSparseVectors
 func (sv1 *GenSparseVector) Dot(svi2 Vector) Value {
 	sv2 := svi2.(*GenSparseVector)
+	return sv1.DotPrecision(sv2, maxPrecision(sv1.precision, sv2.precision))
+}
+
+// dot calculates the dot product accumulating in float32
+func (sv1 *GenSparseVector) dot(sv2 *GenSparseVector) Value {
 
 	var i1, i2 int
 	var dp Value
 	sv1l := sv1.index.Len()
 	sv2l := sv2.index.Len()
+	if sv1l*gallopRatio < sv2l {
+		return gallopDotGen(sv1, sv2)
+	}
+	if sv2l*gallopRatio < sv1l {
+		return gallopDotGen(sv2, sv1)
+	}
 	for i1 < sv1l && i2 < sv2l {
 		if sv1.index.LessThanOther(i1, sv2.index, i2) {
 			i1 += 1
@@ -69,12 +81,7 @@ func (sv1 *GenSparseVector) Dot(svi2 Vector) Value {
 func (v *GenSparseVector) Mag() Value {
 	if !v.magClean {
 		// Could use v1.Dot(v2), but this is more efficient
-		var magsq Value
-		for _, val := range v.values {
-			magsq += val * val
-		}
-		v.mag = Value(math.Sqrt(float64(magsq)))
-
+		v.mag = magPrecision(v.values, v.precision)
 		v.magClean = true
 	}
 	return v.mag
@@ -89,11 +96,7 @@ func (sv1 *GenSparseVector) Cos(svi2 Vector) Value {
 
 // Mean() Calculates the mean element value (mean of values that are present)
 func (sv *GenSparseVector) Mean() Value {
-	var total Value
-	for _, v := range sv.values {
-		total += v
-	}
-	return total / Value(len(sv.values))
+	return meanPrecision(sv.values, sv.precision)
 }
 
 func (sv1 *GenSparseVector) Add(v2 Vector) Vector {
@@ -161,6 +164,7 @@ func (sv *GenSparseVector) AddConst(toAdd Value) {
 		sv.values[i] = v + toAdd
 	}
 	sv.magClean = false
+	debugCheck(sv)
 }
 
 // SubConst subtracts a constant value to each of the present values in the sparse
@@ -175,6 +179,7 @@ func (sv *GenSparseVector) Mult(l Value) {
 		sv.values[i] = l * v
 	}
 	sv.magClean = false
+	debugCheck(sv)
 }
 
 // Iter lets you iterate over the members of the sparse vector
@@ -192,6 +197,7 @@ func (sv *GenSparseVector) IterUpdate(f func(index interface{}, value Value) Val
 		sv.values[i] = f(index, value)
 	}
 	sv.magClean = false
+	debugCheck(sv)
 }
 
 // GetIndices returns the index values of the vector
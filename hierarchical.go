@@ -0,0 +1,259 @@
+package sparsevector
+
+import "errors"
+
+// DistanceFunc measures the distance between two vectors
+type DistanceFunc func(a, b *SparseVectorUint32) Value
+
+// CosineDistance is one minus the cosine of the vectors. It is 0 for vectors
+// pointing the same way, 1 for orthogonal vectors and 2 for opposite vectors.
+// A vector with no entries, or only zeros, has a cosine of zero with every
+// vector, so a distance of 1.
+func CosineDistance(a, b *SparseVectorUint32) Value {
+	m := a.Mag() * b.Mag()
+	if m == 0 {
+		return 1
+	}
+	return 1 - a.Dot(b)/m
+}
+
+// EuclideanDistance is the Euclidean distance between the vectors
+func EuclideanDistance(a, b *SparseVectorUint32) Value {
+	return a.Distance(b)
+}
+
+// Linkage says how the distance between two clusters is measured
+type Linkage byte
+
+const (
+	// SingleLinkage uses the distance between the closest members
+	SingleLinkage Linkage = iota
+	// CompleteLinkage uses the distance between the furthest members
+	CompleteLinkage
+	// AverageLinkage uses the mean distance between all pairs of members
+	AverageLinkage
+	// CentroidLinkage uses the distance between the means of the clusters.
+	// With this linkage a merge can be at a smaller distance than an
+	// earlier one.
+	CentroidLinkage
+)
+
+// Merge is a step in a Dendrogram, where two clusters are merged into one
+type Merge struct {
+	// A and B are the clusters merged. Clusters below the number of vectors
+	// are single vectors. Cluster n + i is the cluster made by merge i.
+	A, B int
+	// Distance is the distance between A and B when they were merged
+	Distance Value
+	// Size is the number of vectors in the merged cluster
+	Size int
+}
+
+// Dendrogram records how Agglomerate merged vectors into clusters
+type Dendrogram struct {
+	// N is the number of vectors clustered
+	N int
+	// Merges are the merges in the order they were made. There are N - 1 of
+	// them.
+	Merges []Merge
+}
+
+// Agglomerate clusters vectors by hierarchical agglomerative clustering.
+// Each vector starts in its own cluster, and the two closest clusters are
+// merged until only one remains.
+//
+// The distances between all pairs of vectors are held in memory, so this is
+// suitable for thousands of vectors rather than millions.
+func Agglomerate(vectors []*SparseVectorUint32, linkage Linkage, distance DistanceFunc) (*Dendrogram, error) {
+	if linkage > CentroidLinkage {
+		return nil, errors.New("sparsevector: unknown linkage")
+	}
+	n := len(vectors)
+	d := &Dendrogram{N: n}
+	if n == 0 {
+		return d, nil
+	}
+
+	dist := newTriangle(n)
+	for i := 1; i < n; i++ {
+		for j := 0; j < i; j++ {
+			dist.set(i, j, distance(vectors[i], vectors[j]))
+		}
+	}
+
+	// id is the dendrogram cluster number for each active row, and size
+	// the number of vectors it holds
+	id := make([]int, n)
+	size := make([]int, n)
+	active := make([]bool, n)
+	var centroids []*SparseVectorUint32
+	var acc *Accumulator
+	if linkage == CentroidLinkage {
+		centroids = append([]*SparseVectorUint32(nil), vectors...)
+		acc = NewAccumulator(0)
+	}
+	for i := range id {
+		id[i] = i
+		size[i] = 1
+		active[i] = true
+	}
+
+	// nn is the nearest active row to each active row
+	nn := make([]int, n)
+	nnDist := make([]Value, n)
+	nearest := func(i int) {
+		nn[i] = -1
+		for j := 0; j < n; j++ {
+			if j != i && active[j] && (nn[i] < 0 || dist.get(i, j) < nnDist[i]) {
+				nn[i], nnDist[i] = j, dist.get(i, j)
+			}
+		}
+	}
+	for i := range nn {
+		nearest(i)
+	}
+
+	for step := 0; step < n-1; step++ {
+		a := -1
+		for i := 0; i < n; i++ {
+			if active[i] && (a < 0 || nnDist[i] < nnDist[a]) {
+				a = i
+			}
+		}
+		b := nn[a]
+		if b < a {
+			a, b = b, a
+		}
+		merged := size[a] + size[b]
+		ida, idb := id[a], id[b]
+		if ida > idb {
+			ida, idb = idb, ida
+		}
+		d.Merges = append(d.Merges, Merge{A: ida, B: idb, Distance: dist.get(a, b), Size: merged})
+
+		// Row a becomes the merged cluster, and row b is dropped
+		active[b] = false
+		if linkage == CentroidLinkage {
+			acc.AddScaled(centroids[a], Value(size[a])/Value(merged))
+			acc.AddScaled(centroids[b], Value(size[b])/Value(merged))
+			centroids[a] = acc.Sum()
+			centroids[b] = nil
+		}
+		for k := 0; k < n; k++ {
+			if !active[k] || k == a {
+				continue
+			}
+			var dk Value
+			da, db := dist.get(a, k), dist.get(b, k)
+			switch linkage {
+			case SingleLinkage:
+				dk = da
+				if db < dk {
+					dk = db
+				}
+			case CompleteLinkage:
+				dk = da
+				if db > dk {
+					dk = db
+				}
+			case AverageLinkage:
+				dk = (Value(size[a])*da + Value(size[b])*db) / Value(merged)
+			case CentroidLinkage:
+				dk = distance(centroids[a], centroids[k])
+			}
+			dist.set(a, k, dk)
+		}
+		id[a] = n + step
+		size[a] = merged
+
+		for k := 0; k < n; k++ {
+			if !active[k] || k == a {
+				continue
+			}
+			if nn[k] == a || nn[k] == b {
+				nearest(k)
+			} else if dk := dist.get(a, k); dk < nnDist[k] || (dk == nnDist[k] && a < nn[k]) {
+				nn[k], nnDist[k] = a, dk
+			}
+		}
+		nearest(a)
+	}
+	return d, nil
+}
+
+// triangle is a symmetric matrix with an empty diagonal
+type triangle struct {
+	values []Value
+}
+
+func newTriangle(n int) triangle {
+	return triangle{values: make([]Value, n*(n-1)/2)}
+}
+
+func (t triangle) pos(i, j int) int {
+	if i < j {
+		i, j = j, i
+	}
+	return i*(i-1)/2 + j
+}
+
+func (t triangle) get(i, j int) Value    { return t.values[t.pos(i, j)] }
+func (t triangle) set(i, j int, v Value) { t.values[t.pos(i, j)] = v }
+
+// CutThreshold returns a cluster label for each vector, from making the
+// merges in order until one is at a distance greater than threshold. Labels
+// are numbered from 0 in order of the first vector in each cluster.
+func (d *Dendrogram) CutThreshold(threshold Value) []int {
+	var m int
+	for m < len(d.Merges) && d.Merges[m].Distance <= threshold {
+		m++
+	}
+	return d.cut(m)
+}
+
+// CutK returns a cluster label for each vector, from making merges until
+// there are k clusters. Labels are numbered from 0 in order of the first
+// vector in each cluster.
+func (d *Dendrogram) CutK(k int) []int {
+	if k < 1 {
+		k = 1
+	}
+	m := d.N - k
+	if m < 0 {
+		m = 0
+	}
+	return d.cut(m)
+}
+
+// cut makes the first m merges, and labels the resulting clusters
+func (d *Dendrogram) cut(m int) []int {
+	// parent is a union-find over all the clusters in the dendrogram
+	parent := make([]int, d.N+len(d.Merges))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i, merge := range d.Merges[:m] {
+		parent[find(merge.A)] = d.N + i
+		parent[find(merge.B)] = d.N + i
+	}
+
+	labels := make([]int, d.N)
+	roots := make(map[int]int)
+	for i := range labels {
+		root := find(i)
+		label, ok := roots[root]
+		if !ok {
+			label = len(roots)
+			roots[root] = label
+		}
+		labels[i] = label
+	}
+	return labels
+}
@@ -0,0 +1,193 @@
+package sparsevector
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func BenchmarkAgglomerate(b *testing.B) {
+	vectors, _ := genClusterTestVectors(rand.New(rand.NewSource(1)), 10, 50)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		Agglomerate(vectors, AverageLinkage, CosineDistance)
+	}
+}
+
+// point creates a vector for a point in 2D
+func point(x, y Value) *SparseVectorUint32 {
+	return NewSparseVectorUint32([]uint32{0, 1}, []Value{x, y})
+}
+
+func TestAgglomerate(t *testing.T) {
+	points := []*SparseVectorUint32{
+		point(1, 1), point(1.5, 1), point(5, 1), point(6, 1), point(12, 1),
+	}
+	tests := []struct {
+		linkage   Linkage
+		merges    []Merge
+		threshold Value
+		cut       []int
+	}{
+		{SingleLinkage, []Merge{{0, 1, 0.5, 2}, {2, 3, 1, 2}, {5, 6, 3.5, 4}, {4, 7, 6, 5}}, 4, []int{0, 0, 0, 0, 1}},
+		{CompleteLinkage, []Merge{{0, 1, 0.5, 2}, {2, 3, 1, 2}, {5, 6, 5, 4}, {4, 7, 11, 5}}, 4, []int{0, 0, 1, 1, 2}},
+		{AverageLinkage, []Merge{{0, 1, 0.5, 2}, {2, 3, 1, 2}, {5, 6, 4.25, 4}, {4, 7, 8.625, 5}}, 4.25, []int{0, 0, 0, 0, 1}},
+		{CentroidLinkage, []Merge{{0, 1, 0.5, 2}, {2, 3, 1, 2}, {5, 6, 4.25, 4}, {4, 7, 8.625, 5}}, 1, []int{0, 0, 1, 1, 2}},
+	}
+
+	for i, test := range tests {
+		d, err := Agglomerate(points, test.linkage, EuclideanDistance)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(d.Merges) != len(test.merges) {
+			t.Fatalf("Test %d. Have %d merges", i, len(d.Merges))
+		}
+		for j, m := range d.Merges {
+			exp := test.merges[j]
+			if m.A != exp.A || m.B != exp.B || m.Size != exp.Size || math.Abs(float64(m.Distance-exp.Distance)) > 1e-5 {
+				t.Errorf("Test %d. Merge %d is %v, expected %v", i, j, m, exp)
+			}
+		}
+		if cut := d.CutThreshold(test.threshold); !reflect.DeepEqual(test.cut, cut) {
+			t.Errorf("Test %d. Threshold cut is %v, expected %v", i, cut, test.cut)
+		}
+	}
+
+	d, _ := Agglomerate(points, SingleLinkage, EuclideanDistance)
+	cuts := [][]int{
+		{0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 1},
+		{0, 0, 1, 1, 2},
+		{0, 0, 1, 2, 3},
+		{0, 1, 2, 3, 4},
+		{0, 1, 2, 3, 4},
+	}
+	for k, exp := range cuts {
+		if cut := d.CutK(k + 1); !reflect.DeepEqual(exp, cut) {
+			t.Errorf("Cut at %d clusters is %v, expected %v", k+1, cut, exp)
+		}
+	}
+
+	if d, err := Agglomerate(nil, SingleLinkage, EuclideanDistance); err != nil || len(d.CutK(1)) != 0 {
+		t.Errorf("empty clustering not as expected. %v", err)
+	}
+	if _, err := Agglomerate(points, Linkage(9), EuclideanDistance); err == nil {
+		t.Errorf("expected an error for an unknown linkage")
+	}
+}
+
+// naiveAgglomerate merges clusters by calculating every cluster distance at
+// every step
+func naiveAgglomerate(vectors []*SparseVectorUint32, linkage Linkage, distance DistanceFunc) []Value {
+	clusters := make([][]int, len(vectors))
+	for i := range clusters {
+		clusters[i] = []int{i}
+	}
+	mean := func(c []int) *SparseVectorUint32 {
+		a := NewAccumulator(0)
+		for _, i := range c {
+			a.AddScaled(vectors[i], 1/Value(len(c)))
+		}
+		return a.Sum()
+	}
+	clusterDist := func(c1, c2 []int) Value {
+		if linkage == CentroidLinkage {
+			return distance(mean(c1), mean(c2))
+		}
+		var total, best Value
+		for n, i := range c1 {
+			for m, j := range c2 {
+				d := distance(vectors[i], vectors[j])
+				total += d
+				if (n == 0 && m == 0) || (linkage == SingleLinkage && d < best) || (linkage == CompleteLinkage && d > best) {
+					best = d
+				}
+			}
+		}
+		if linkage == AverageLinkage {
+			return total / Value(len(c1)*len(c2))
+		}
+		return best
+	}
+
+	var dists []Value
+	for len(clusters) > 1 {
+		bi, bj := 0, 1
+		best := clusterDist(clusters[0], clusters[1])
+		for i := range clusters {
+			for j := i + 1; j < len(clusters); j++ {
+				if d := clusterDist(clusters[i], clusters[j]); d < best {
+					bi, bj, best = i, j, d
+				}
+			}
+		}
+		dists = append(dists, best)
+		clusters[bi] = append(clusters[bi], clusters[bj]...)
+		clusters = append(clusters[:bj], clusters[bj+1:]...)
+	}
+	return dists
+}
+
+func TestCosineDistance(t *testing.T) {
+	empty := NewSparseVectorUint32([]uint32{}, []Value{})
+	zero := NewSparseVectorUint32([]uint32{1}, []Value{0})
+	a := NewSparseVectorUint32([]uint32{1, 2}, []Value{1, 1})
+	tests := []struct {
+		a, b *SparseVectorUint32
+		exp  Value
+	}{
+		{a, a, 0},
+		{a, NewSparseVectorUint32([]uint32{3}, []Value{1}), 1},
+		{a, NewSparseVectorUint32([]uint32{1, 2}, []Value{-2, -2}), 2},
+		{a, empty, 1},
+		{empty, a, 1},
+		{empty, empty, 1},
+		{zero, a, 1},
+	}
+	for i, test := range tests {
+		if d := CosineDistance(test.a, test.b); math.Abs(float64(d-test.exp)) > 1e-6 {
+			t.Errorf("Test %d. Distance is %f, expected %f", i, d, test.exp)
+		}
+	}
+
+	// An empty vector doesn't upset the linkage
+	d, err := Agglomerate([]*SparseVectorUint32{a, empty, a}, AverageLinkage, CosineDistance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Merges) != 2 || d.Merges[0].A != 0 || d.Merges[0].B != 2 || d.Merges[1].Distance != 1 {
+		t.Errorf("Merges not as expected. Have %v", d.Merges)
+	}
+}
+
+func TestAgglomerateRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	vectors := make([]*SparseVectorUint32, 25)
+	for i := range vectors {
+		vectors[i] = genSpreadSparseVector(rnd, 5, 20)
+	}
+
+	for _, linkage := range []Linkage{SingleLinkage, CompleteLinkage, AverageLinkage, CentroidLinkage} {
+		for _, distance := range []DistanceFunc{EuclideanDistance, CosineDistance} {
+			d, err := Agglomerate(vectors, linkage, distance)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exp := naiveAgglomerate(vectors, linkage, distance)
+			for i, m := range d.Merges {
+				if math.Abs(float64(m.Distance-exp[i])) > 1e-4 {
+					t.Errorf("Linkage %d. Merge %d at %f, expected %f", linkage, i, m.Distance, exp[i])
+					break
+				}
+			}
+			if last := d.Merges[len(d.Merges)-1]; last.Size != len(vectors) {
+				t.Errorf("Linkage %d. Last merge has size %d", linkage, last.Size)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package sparsevector
+
+// intersectBatch is the number of matching positions we collect from the SIMD
+// intersection kernel before using them
+const intersectBatch = 256
+
+// dotUint32 calculates the dot product of two vectors held as parallel
+// arrays of sorted indices and values.
+//
+// Where the CPU supports it, matching indices are found by a SIMD kernel that
+// compares whole blocks of indices at once. The kernel reports matches in
+// index order, so the products are summed in exactly the same order as the
+// scalar loop and the result is identical.
+//
+// If one vector is much longer than the other we instead search the long one
+// for each index of the short one.
+func dotUint32(ai []uint32, av []Value, bi []uint32, bv []Value) Value {
+	if len(ai)*gallopRatio < len(bi) {
+		return gallopDotUint32(ai, av, bi, bv)
+	}
+	if len(bi)*gallopRatio < len(ai) {
+		return gallopDotUint32(bi, bv, ai, av)
+	}
+
+	var dp Value
+	var i1, i2 int
+	if haveSIMDIntersect {
+		var pa, pb [intersectBatch]int32
+		for {
+			n, d1, d2 := intersectSIMD(ai[i1:], bi[i2:], pa[:], pb[:])
+			for k, p := range pa[:n] {
+				dp += av[i1+int(p)] * bv[i2+int(pb[k])]
+			}
+			if d1 == 0 && d2 == 0 {
+				break
+			}
+			i1 += d1
+			i2 += d2
+		}
+	}
+
+	// Finish off with a scalar merge
+	l1 := len(ai)
+	l2 := len(bi)
+	for i1 < l1 && i2 < l2 {
+		if ai[i1] < bi[i2] {
+			i1 += 1
+		} else if bi[i2] < ai[i1] {
+			i2 += 1
+		} else {
+			dp += av[i1] * bv[i2]
+			i1 += 1
+			i2 += 1
+		}
+	}
+	return dp
+}
+
+// intersectionSize counts the indices present in both of two sorted lists
+func intersectionSize(a, b []uint32) int {
+	var count int
+	var i1, i2 int
+	if haveSIMDIntersect {
+		var pa, pb [intersectBatch]int32
+		for {
+			n, d1, d2 := intersectSIMD(a[i1:], b[i2:], pa[:], pb[:])
+			count += n
+			if d1 == 0 && d2 == 0 {
+				break
+			}
+			i1 += d1
+			i2 += d2
+		}
+	}
+
+	l1 := len(a)
+	l2 := len(b)
+	for i1 < l1 && i2 < l2 {
+		if a[i1] < b[i2] {
+			i1 += 1
+		} else if b[i2] < a[i1] {
+			i2 += 1
+		} else {
+			count++
+			i1 += 1
+			i2 += 1
+		}
+	}
+	return count
+}
+
+// Jaccard calculates the Jaccard similarity of the sets of indices present in
+// two vectors. This is the number of indices present in both divided by the
+// number present in either. Values are ignored.
+func (sv1 *SparseVectorUint32) Jaccard(sv2 *SparseVectorUint32) Value {
+	common := intersectionSize(sv1.indices, sv2.indices)
+	return Value(common) / Value(len(sv1.indices)+len(sv2.indices)-common)
+}
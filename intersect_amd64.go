@@ -0,0 +1,56 @@
+//go:build amd64 && !purego
+
+package sparsevector
+
+// useAVX2 selects the AVX2 kernel, which compares blocks of 8 indices. If
+// it's not set we use the SSE2 kernel, which compares blocks of 4. That
+// kernel needs nothing from SSE4, and SSE2 is present on every amd64 CPU.
+var useAVX2 = cpuHasAVX2()
+
+const haveSIMDIntersect = true
+
+// intersectSIMD finds matching indices in two sorted lists. It works
+// through whole blocks of a and b, writing the positions of the matches in
+// index order to pa and pb. It stops when either list has no whole blocks
+// left with an index after them, when pa or pb may not have room for another
+// block's matches, or when it finds a repeated index. It returns the number
+// of matches found and how far it got through a and b. The caller should
+// finish off any remainder with a scalar merge.
+func intersectSIMD(a, b []uint32, pa, pb []int32) (n, i, j int) {
+	if useAVX2 {
+		return intersectAVX2(a, b, pa, pb)
+	}
+	return intersectSSE2(a, b, pa, pb)
+}
+
+//go:noescape
+func intersectSSE2(a, b []uint32, pa, pb []int32) (n, i, j int)
+
+//go:noescape
+func intersectAVX2(a, b []uint32, pa, pb []int32) (n, i, j int)
+
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+func xgetbv() (eax, edx uint32)
+
+func cpuHasAVX2() bool {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 7 {
+		return false
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	const (
+		osxsave = 1 << 27
+		avx     = 1 << 28
+	)
+	if ecx1&osxsave == 0 || ecx1&avx == 0 {
+		return false
+	}
+	// Check the OS saves the YMM registers
+	if xcr0, _ := xgetbv(); xcr0&6 != 6 {
+		return false
+	}
+	_, ebx7, _, _ := cpuid(7, 0)
+	const avx2 = 1 << 5
+	return ebx7&avx2 != 0
+}
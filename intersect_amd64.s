@@ -0,0 +1,386 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// The intersection kernels compare a block of indices from a with a block
+// from b. Comparing a with each rotation of b finds every equal pair in the
+// two blocks. From the comparisons we build a mask of the lanes of a that
+// match, and a mask of the lanes of b that match. Indices are sorted and
+// unique, so the k-th match in a pairs with the k-th match in b, and we can
+// write out the pairs in index order.
+//
+// Repeated indices would break this pairing, and could pair an index in one
+// block with one already matched in an earlier block. So each block is also
+// compared with itself shifted by one lane, which finds any index equal to
+// the next, including the first index after the block. If there are any we
+// stop before writing out the block's matches, and leave the rest to the
+// caller's scalar merge. This is why a block is only compared if there's at
+// least one index after it.
+//
+// Then we move on from whichever block has the smaller last index, or both
+// if the last indices are equal.
+//
+// The narrower kernel only needs SSE2 rather than SSE4. It uses 32-bit lane
+// compares (PCMPEQL), shuffles (PSHUFD) and MOVMSKPS, which are all SSE2.
+// SSE4.1 adds nothing this kernel uses, and SSE2 is present on every amd64
+// CPU, so it needs no CPU feature check.
+//
+// Register use
+//	SI, DI   a, b
+//	R8, R9   last position in a, b at which a whole block starts
+//	R10, R11 pa, pb
+//	R12      largest number of matches at which we have room for another block
+//	AX       number of matches
+//	BX, CX   position in a, b
+//	DX, R13  masks of the matching lanes of a, b
+
+// Indices for VPERMD to rotate a vector of 8 uint32 by 1 to 7 lanes
+DATA rotidx<>+0(SB)/4, $1
+DATA rotidx<>+4(SB)/4, $2
+DATA rotidx<>+8(SB)/4, $3
+DATA rotidx<>+12(SB)/4, $4
+DATA rotidx<>+16(SB)/4, $5
+DATA rotidx<>+20(SB)/4, $6
+DATA rotidx<>+24(SB)/4, $7
+DATA rotidx<>+28(SB)/4, $0
+DATA rotidx<>+32(SB)/4, $2
+DATA rotidx<>+36(SB)/4, $3
+DATA rotidx<>+40(SB)/4, $4
+DATA rotidx<>+44(SB)/4, $5
+DATA rotidx<>+48(SB)/4, $6
+DATA rotidx<>+52(SB)/4, $7
+DATA rotidx<>+56(SB)/4, $0
+DATA rotidx<>+60(SB)/4, $1
+DATA rotidx<>+64(SB)/4, $3
+DATA rotidx<>+68(SB)/4, $4
+DATA rotidx<>+72(SB)/4, $5
+DATA rotidx<>+76(SB)/4, $6
+DATA rotidx<>+80(SB)/4, $7
+DATA rotidx<>+84(SB)/4, $0
+DATA rotidx<>+88(SB)/4, $1
+DATA rotidx<>+92(SB)/4, $2
+DATA rotidx<>+96(SB)/4, $4
+DATA rotidx<>+100(SB)/4, $5
+DATA rotidx<>+104(SB)/4, $6
+DATA rotidx<>+108(SB)/4, $7
+DATA rotidx<>+112(SB)/4, $0
+DATA rotidx<>+116(SB)/4, $1
+DATA rotidx<>+120(SB)/4, $2
+DATA rotidx<>+124(SB)/4, $3
+DATA rotidx<>+128(SB)/4, $5
+DATA rotidx<>+132(SB)/4, $6
+DATA rotidx<>+136(SB)/4, $7
+DATA rotidx<>+140(SB)/4, $0
+DATA rotidx<>+144(SB)/4, $1
+DATA rotidx<>+148(SB)/4, $2
+DATA rotidx<>+152(SB)/4, $3
+DATA rotidx<>+156(SB)/4, $4
+DATA rotidx<>+160(SB)/4, $6
+DATA rotidx<>+164(SB)/4, $7
+DATA rotidx<>+168(SB)/4, $0
+DATA rotidx<>+172(SB)/4, $1
+DATA rotidx<>+176(SB)/4, $2
+DATA rotidx<>+180(SB)/4, $3
+DATA rotidx<>+184(SB)/4, $4
+DATA rotidx<>+188(SB)/4, $5
+DATA rotidx<>+192(SB)/4, $7
+DATA rotidx<>+196(SB)/4, $0
+DATA rotidx<>+200(SB)/4, $1
+DATA rotidx<>+204(SB)/4, $2
+DATA rotidx<>+208(SB)/4, $3
+DATA rotidx<>+212(SB)/4, $4
+DATA rotidx<>+216(SB)/4, $5
+DATA rotidx<>+220(SB)/4, $6
+GLOBL rotidx<>(SB), RODATA|NOPTR, $224
+
+// func intersectSSE2(a, b []uint32, pa, pb []int32) (n, i, j int)
+TEXT ·intersectSSE2(SB), NOSPLIT, $0-120
+	MOVQ a_base+0(FP), SI
+	MOVQ a_len+8(FP), R8
+	MOVQ b_base+24(FP), DI
+	MOVQ b_len+32(FP), R9
+	MOVQ pa_base+48(FP), R10
+	MOVQ pa_len+56(FP), R12
+	MOVQ pb_base+72(FP), R11
+	MOVQ pb_len+80(FP), DX
+	CMPQ DX, R12
+	CMOVQLT DX, R12
+	SUBQ $4, R12
+	SUBQ $4, R8
+	SUBQ $4, R9
+	XORQ AX, AX
+	XORQ BX, BX
+	XORQ CX, CX
+
+sseloop:
+	CMPQ BX, R8
+	JGE  ssedone
+	CMPQ CX, R9
+	JGE  ssedone
+	CMPQ AX, R12
+	JGT  ssedone
+
+	MOVOU   (SI)(BX*4), X0
+	MOVOU   (DI)(CX*4), X1
+
+	// Check for repeated indices
+	MOVOU    4(SI)(BX*4), X6
+	PCMPEQL  X0, X6
+	MOVOU    4(DI)(CX*4), X7
+	PCMPEQL  X1, X7
+	POR      X7, X6
+	MOVMSKPS X6, DX
+	TESTL    DX, DX
+	JNZ      ssedone
+
+	MOVO    X1, X2
+	PCMPEQL X0, X2
+	PSHUFD  $0x39, X1, X3
+	PCMPEQL X0, X3
+	PSHUFD  $0x4e, X1, X4
+	PCMPEQL X0, X4
+	PSHUFD  $0x93, X1, X5
+	PCMPEQL X0, X5
+
+	MOVMSKPS X2, DX
+	MOVL     DX, R13
+	MOVMSKPS X3, R14
+	ORL      R14, DX
+	IMUL3L   $17, R14, R14
+	SHRL     $3, R14
+	ANDL     $15, R14
+	ORL      R14, R13
+
+	MOVMSKPS X4, R14
+	ORL      R14, DX
+	IMUL3L   $17, R14, R14
+	SHRL     $2, R14
+	ANDL     $15, R14
+	ORL      R14, R13
+
+	MOVMSKPS X5, R14
+	ORL      R14, DX
+	IMUL3L   $17, R14, R14
+	SHRL     $1, R14
+	ANDL     $15, R14
+	ORL      R14, R13
+
+	TESTL DX, DX
+	JZ    sseadvance
+
+sseemit:
+	BSFL  DX, R14
+	ADDQ  BX, R14
+	MOVL  R14, (R10)(AX*4)
+	BSFL  R13, R14
+	ADDQ  CX, R14
+	MOVL  R14, (R11)(AX*4)
+	INCQ  AX
+	LEAL  -1(DX), R14
+	ANDL  R14, DX
+	LEAL  -1(R13), R14
+	ANDL  R14, R13
+	TESTL DX, DX
+	JNZ   sseemit
+
+sseadvance:
+	MOVL 12(SI)(BX*4), DX
+	MOVL 12(DI)(CX*4), R13
+	CMPL DX, R13
+	JA   ssebonly
+	ADDQ $4, BX
+	CMPL DX, R13
+	JB   sseloop
+	ADDQ $4, CX
+	JMP  sseloop
+
+ssebonly:
+	ADDQ $4, CX
+	JMP  sseloop
+
+ssedone:
+	MOVQ AX, n+96(FP)
+	MOVQ BX, i+104(FP)
+	MOVQ CX, j+112(FP)
+	RET
+
+// func intersectAVX2(a, b []uint32, pa, pb []int32) (n, i, j int)
+TEXT ·intersectAVX2(SB), NOSPLIT, $0-120
+	MOVQ a_base+0(FP), SI
+	MOVQ a_len+8(FP), R8
+	MOVQ b_base+24(FP), DI
+	MOVQ b_len+32(FP), R9
+	MOVQ pa_base+48(FP), R10
+	MOVQ pa_len+56(FP), R12
+	MOVQ pb_base+72(FP), R11
+	MOVQ pb_len+80(FP), DX
+	CMPQ DX, R12
+	CMOVQLT DX, R12
+	SUBQ $8, R12
+	SUBQ $8, R8
+	SUBQ $8, R9
+	XORQ AX, AX
+	XORQ BX, BX
+	XORQ CX, CX
+
+	VMOVDQU rotidx<>+0(SB), Y8
+	VMOVDQU rotidx<>+32(SB), Y9
+	VMOVDQU rotidx<>+64(SB), Y10
+	VMOVDQU rotidx<>+96(SB), Y11
+	VMOVDQU rotidx<>+128(SB), Y12
+	VMOVDQU rotidx<>+160(SB), Y13
+	VMOVDQU rotidx<>+192(SB), Y14
+
+avxloop:
+	CMPQ BX, R8
+	JGE  avxdone
+	CMPQ CX, R9
+	JGE  avxdone
+	CMPQ AX, R12
+	JGT  avxdone
+
+	VMOVDQU   (SI)(BX*4), Y0
+	VMOVDQU   (DI)(CX*4), Y1
+
+	// Check for repeated indices
+	VMOVDQU   4(SI)(BX*4), Y3
+	VPCMPEQD  Y3, Y0, Y3
+	VMOVDQU   4(DI)(CX*4), Y4
+	VPCMPEQD  Y4, Y1, Y4
+	VPOR      Y4, Y3, Y3
+	VMOVMSKPS Y3, DX
+	TESTL     DX, DX
+	JNZ       avxdone
+
+	VPCMPEQD  Y1, Y0, Y2
+	VMOVMSKPS Y2, DX
+	MOVL      DX, R13
+
+	// Rotate b by 1
+	VPERMD   Y1, Y8, Y2
+	VPCMPEQD Y2, Y0, Y2
+	VMOVMSKPS Y2, R14
+	ORL      R14, DX
+	IMUL3L   $0x101, R14, R14
+	SHRL     $7, R14
+	ANDL     $0xff, R14
+	ORL      R14, R13
+
+	// Rotate b by 2
+	VPERMD   Y1, Y9, Y2
+	VPCMPEQD Y2, Y0, Y2
+	VMOVMSKPS Y2, R14
+	ORL      R14, DX
+	IMUL3L   $0x101, R14, R14
+	SHRL     $6, R14
+	ANDL     $0xff, R14
+	ORL      R14, R13
+
+	// Rotate b by 3
+	VPERMD   Y1, Y10, Y2
+	VPCMPEQD Y2, Y0, Y2
+	VMOVMSKPS Y2, R14
+	ORL      R14, DX
+	IMUL3L   $0x101, R14, R14
+	SHRL     $5, R14
+	ANDL     $0xff, R14
+	ORL      R14, R13
+
+	// Rotate b by 4
+	VPERMD   Y1, Y11, Y2
+	VPCMPEQD Y2, Y0, Y2
+	VMOVMSKPS Y2, R14
+	ORL      R14, DX
+	IMUL3L   $0x101, R14, R14
+	SHRL     $4, R14
+	ANDL     $0xff, R14
+	ORL      R14, R13
+
+	// Rotate b by 5
+	VPERMD   Y1, Y12, Y2
+	VPCMPEQD Y2, Y0, Y2
+	VMOVMSKPS Y2, R14
+	ORL      R14, DX
+	IMUL3L   $0x101, R14, R14
+	SHRL     $3, R14
+	ANDL     $0xff, R14
+	ORL      R14, R13
+
+	// Rotate b by 6
+	VPERMD   Y1, Y13, Y2
+	VPCMPEQD Y2, Y0, Y2
+	VMOVMSKPS Y2, R14
+	ORL      R14, DX
+	IMUL3L   $0x101, R14, R14
+	SHRL     $2, R14
+	ANDL     $0xff, R14
+	ORL      R14, R13
+
+	// Rotate b by 7
+	VPERMD   Y1, Y14, Y2
+	VPCMPEQD Y2, Y0, Y2
+	VMOVMSKPS Y2, R14
+	ORL      R14, DX
+	IMUL3L   $0x101, R14, R14
+	SHRL     $1, R14
+	ANDL     $0xff, R14
+	ORL      R14, R13
+
+	TESTL DX, DX
+	JZ    avxadvance
+
+avxemit:
+	BSFL  DX, R14
+	ADDQ  BX, R14
+	MOVL  R14, (R10)(AX*4)
+	BSFL  R13, R14
+	ADDQ  CX, R14
+	MOVL  R14, (R11)(AX*4)
+	INCQ  AX
+	LEAL  -1(DX), R14
+	ANDL  R14, DX
+	LEAL  -1(R13), R14
+	ANDL  R14, R13
+	TESTL DX, DX
+	JNZ   avxemit
+
+avxadvance:
+	MOVL 28(SI)(BX*4), DX
+	MOVL 28(DI)(CX*4), R13
+	CMPL DX, R13
+	JA   avxbonly
+	ADDQ $8, BX
+	CMPL DX, R13
+	JB   avxloop
+	ADDQ $8, CX
+	JMP  avxloop
+
+avxbonly:
+	ADDQ $8, CX
+	JMP  avxloop
+
+avxdone:
+	VZEROUPPER
+	MOVQ AX, n+96(FP)
+	MOVQ BX, i+104(FP)
+	MOVQ CX, j+112(FP)
+	RET
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
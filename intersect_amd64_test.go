@@ -0,0 +1,71 @@
+//go:build amd64 && !purego
+
+package sparsevector
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestIntersectKernels(t *testing.T) {
+	kernels := map[string]func(a, b []uint32, pa, pb []int32) (n, i, j int){
+		"sse2": intersectSSE2,
+	}
+	if cpuHasAVX2() {
+		kernels["avx2"] = intersectAVX2
+	} else {
+		t.Logf("CPU does not support AVX2")
+	}
+
+	rnd := rand.New(rand.NewSource(2))
+	pairs := genIntersectTestVectors(rnd, 300)
+	for name, kernel := range kernels {
+		for i, pair := range pairs {
+			a, b := pair[0].indices, pair[1].indices
+
+			// Drive the kernel with a small buffer so we test stopping when
+			// the buffer fills
+			var pa, pb [20]int32
+			var matches [][2]int
+			var i1, i2 int
+			for {
+				n, d1, d2 := kernel(a[i1:], b[i2:], pa[:], pb[:])
+				for k := 0; k < n; k++ {
+					matches = append(matches, [2]int{i1 + int(pa[k]), i2 + int(pb[k])})
+				}
+				if d1 == 0 && d2 == 0 {
+					break
+				}
+				i1 += d1
+				i2 += d2
+			}
+
+			for k, m := range matches {
+				if a[m[0]] != b[m[1]] {
+					t.Fatalf("%s test %d. match %d is not a match", name, i, k)
+				}
+				if k > 0 && (m[0] <= matches[k-1][0] || m[1] <= matches[k-1][1]) {
+					t.Fatalf("%s test %d. match %d is out of order", name, i, k)
+				}
+			}
+
+			// Add the matches from the scalar tail, and we should have them all
+			count := len(matches) + scalarIntersectionSize(a[i1:], b[i2:])
+			if exp := scalarIntersectionSize(a, b); count != exp {
+				t.Errorf("%s test %d. found %d matches, expected %d", name, i, count, exp)
+			}
+		}
+	}
+}
+
+func BenchmarkSparseVector10000SSE2(b *testing.B) {
+	defer func(old bool) { useAVX2 = old }(useAVX2)
+	useAVX2 = false
+	benchmarkSparseVectorM(b, 10000)
+}
+
+func TestDotRepeatedIndicesSSE2(t *testing.T) {
+	defer func(old bool) { useAVX2 = old }(useAVX2)
+	useAVX2 = false
+	TestDotRepeatedIndices(t)
+}
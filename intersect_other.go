@@ -0,0 +1,9 @@
+//go:build !amd64 || purego
+
+package sparsevector
+
+const haveSIMDIntersect = false
+
+func intersectSIMD(a, b []uint32, pa, pb []int32) (n, i, j int) {
+	return 0, 0, 0
+}
@@ -0,0 +1,147 @@
+package sparsevector
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// scalarDot is the plain merge loop the SIMD version must agree with exactly
+func scalarDot(sv1, sv2 *SparseVectorUint32) Value {
+	var i1, i2 int
+	var dp Value
+	for i1 < len(sv1.indices) && i2 < len(sv2.indices) {
+		if sv1.indices[i1] < sv2.indices[i2] {
+			i1 += 1
+		} else if sv2.indices[i2] < sv1.indices[i1] {
+			i2 += 1
+		} else {
+			dp += sv1.values[i1] * sv2.values[i2]
+			i1 += 1
+			i2 += 1
+		}
+	}
+	return dp
+}
+
+func scalarIntersectionSize(a, b []uint32) int {
+	set := make(map[uint32]bool, len(a))
+	for _, v := range a {
+		set[v] = true
+	}
+	var count int
+	for _, v := range b {
+		if set[v] {
+			count++
+		}
+	}
+	return count
+}
+
+// genIntersectTestVectors makes pairs of vectors with a wide range of lengths
+// and overlaps, including some with more matches than fit in one batch
+func genIntersectTestVectors(rnd *rand.Rand, n int) [][2]*SparseVectorUint32 {
+	pairs := make([][2]*SparseVectorUint32, n)
+	for i := range pairs {
+		m := 1 + rnd.Intn(5000)
+		pairs[i][0] = genSpreadSparseVector(rnd, rnd.Intn(m+1), m)
+		pairs[i][1] = genSpreadSparseVector(rnd, rnd.Intn(m+1), m)
+		for _, sv := range pairs[i] {
+			for j := range sv.values {
+				// Values that don't sum exactly, so order matters
+				sv.values[j] = Value(rnd.Float32()*100 - 50)
+			}
+		}
+	}
+	return pairs
+}
+
+func TestDotIntersect(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i, pair := range genIntersectTestVectors(rnd, 500) {
+		sv1, sv2 := pair[0], pair[1]
+		if dp, exp := sv1.Dot(sv2), scalarDot(sv1, sv2); dp != exp {
+			t.Errorf("Test %d. Dot is %v, expected %v", i, dp, exp)
+		}
+		if dp, exp := sv2.Dot(sv1), scalarDot(sv2, sv1); dp != exp {
+			t.Errorf("Test %d. Reverse Dot is %v, expected %v", i, dp, exp)
+		}
+		if n, exp := intersectionSize(sv1.indices, sv2.indices), scalarIntersectionSize(sv1.indices, sv2.indices); n != exp {
+			t.Errorf("Test %d. intersection size is %d, expected %d", i, n, exp)
+		}
+	}
+}
+
+func TestDotRepeatedIndices(t *testing.T) {
+	// Vectors with repeated indices are invalid, but NewSparseVectorUint32
+	// accepts them. Every path must still pair repeats as the scalar merge
+	// does. Struct literals avoid the debug checks.
+	rnd := rand.New(rand.NewSource(1))
+	gen := func(l, m int) *SparseVectorUint32 {
+		sv := &SparseVectorUint32{indices: make([]uint32, l), values: make([]Value, l)}
+		for i := range sv.indices {
+			sv.indices[i] = uint32(rnd.Intn(m))
+			sv.values[i] = Value(rnd.Float32()*100 - 50)
+		}
+		sort.Sort(sparseVectorUint32Sort{sv})
+		return sv
+	}
+	ones := func(sv *SparseVectorUint32) *SparseVectorUint32 {
+		c := &SparseVectorUint32{indices: sv.indices, values: make([]Value, len(sv.indices))}
+		for i := range c.values {
+			c.values[i] = 1
+		}
+		return c
+	}
+
+	tests := [][2]*SparseVectorUint32{
+		{
+			{indices: []uint32{1, 2, 3, 5, 5, 6, 7, 8, 9, 10}, values: []Value{1, 1, 1, 2, 3, 1, 1, 1, 1, 1}},
+			{indices: []uint32{5, 9, 10, 11, 12, 13, 14, 15, 16, 17}, values: []Value{4, 1, 1, 1, 1, 1, 1, 1, 1, 1}},
+		},
+	}
+	for i := 0; i < 500; i++ {
+		m := 1 + rnd.Intn(2000)
+		tests = append(tests, [2]*SparseVectorUint32{gen(rnd.Intn(1000), m), gen(rnd.Intn(1000), m)})
+	}
+
+	for i, pair := range tests {
+		sv1, sv2 := pair[0], pair[1]
+		if dp, exp := sv1.Dot(sv2), scalarDot(sv1, sv2); dp != exp {
+			t.Errorf("Test %d. Dot is %v, expected %v", i, dp, exp)
+		}
+		if dp, exp := sv2.Dot(sv1), scalarDot(sv2, sv1); dp != exp {
+			t.Errorf("Test %d. Reverse Dot is %v, expected %v", i, dp, exp)
+		}
+		if n, exp := intersectionSize(sv1.indices, sv2.indices), int(scalarDot(ones(sv1), ones(sv2))); n != exp {
+			t.Errorf("Test %d. intersection size is %d, expected %d", i, n, exp)
+		}
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	sv1 := NewSparseVectorUint32([]uint32{1, 2, 3, 4}, []Value{1, 2, 3, 4})
+	sv2 := NewSparseVectorUint32([]uint32{3, 4, 5}, []Value{7, 8, 9})
+	if j := sv1.Jaccard(sv2); j != Value(2)/5 {
+		t.Errorf("Jaccard not as expected. Have %f", j)
+	}
+	if j := sv1.Jaccard(sv1); j != 1 {
+		t.Errorf("Jaccard with self not as expected. Have %f", j)
+	}
+}
+
+func BenchmarkDotLowOverlap(b *testing.B) {
+	// Two vectors whose indices rarely match, which is where comparing
+	// blocks of indices at once pays off most
+	rnd := rand.New(rand.NewSource(1))
+	v1 := genSpreadSparseVector(rnd, 10000, 200000)
+	v2 := genSpreadSparseVector(rnd, 10000, 200000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var total Value
+	for i := 0; i < b.N; i++ {
+		total += v1.Dot(v2)
+	}
+}
@@ -0,0 +1,81 @@
+package sparsevector
+
+import "sort"
+
+// InvertedIndex holds a collection of vectors so that the dot products of a
+// query with all of them can be found quickly. For each index it keeps a
+// posting list of the vectors with a value at that index, so only vectors
+// that share an index with the query are visited.
+//
+// An InvertedIndex reuses a workspace between queries, so like the vectors
+// themselves it is not safe for concurrent use.
+type InvertedIndex struct {
+	postings map[uint32][]posting
+	vectors  []*SparseVectorUint32
+	scores   []Value
+	seen     []bool
+	touched  []int
+}
+
+// posting is an entry in a posting list
+type posting struct {
+	id    int32
+	value Value
+}
+
+// NewInvertedIndex creates an empty InvertedIndex
+func NewInvertedIndex() *InvertedIndex {
+	return &InvertedIndex{postings: make(map[uint32][]posting)}
+}
+
+// Add adds a vector to the index, and returns its id. Ids are assigned in
+// order from 0. The vector must not be modified while it is in the index.
+func (ix *InvertedIndex) Add(sv *SparseVectorUint32) int {
+	id := len(ix.vectors)
+	for i, index := range sv.indices {
+		ix.postings[index] = append(ix.postings[index], posting{id: int32(id), value: sv.values[i]})
+	}
+	ix.vectors = append(ix.vectors, sv)
+	return id
+}
+
+// Len returns the number of vectors in the index
+func (ix *InvertedIndex) Len() int { return len(ix.vectors) }
+
+// Vector returns the vector with an id
+func (ix *InvertedIndex) Vector(id int) *SparseVectorUint32 { return ix.vectors[id] }
+
+// Dots calculates the dot product of query with every vector in the index
+// that shares an index with it. It returns the ids of those vectors in
+// increasing order, and the dot products. The dot product with every other
+// vector is zero.
+func (ix *InvertedIndex) Dots(query *SparseVectorUint32) ([]int, []Value) {
+	if len(ix.scores) < len(ix.vectors) {
+		ix.scores = make([]Value, len(ix.vectors))
+		ix.seen = make([]bool, len(ix.vectors))
+	}
+	// The query's values are taken in index order, so each dot product is
+	// summed in the same order as SparseVectorUint32.Dot
+	for i, index := range query.indices {
+		qv := query.values[i]
+		for _, p := range ix.postings[index] {
+			if !ix.seen[p.id] {
+				ix.seen[p.id] = true
+				ix.touched = append(ix.touched, int(p.id))
+			}
+			ix.scores[p.id] += qv * p.value
+		}
+	}
+
+	sort.Ints(ix.touched)
+	ids := make([]int, len(ix.touched))
+	dots := make([]Value, len(ix.touched))
+	for i, id := range ix.touched {
+		ids[i] = id
+		dots[i] = ix.scores[id]
+		ix.scores[id] = 0
+		ix.seen[id] = false
+	}
+	ix.touched = ix.touched[:0]
+	return ids, dots
+}
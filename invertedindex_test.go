@@ -0,0 +1,44 @@
+package sparsevector
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestInvertedIndex(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	ix := NewInvertedIndex()
+	var vectors []*SparseVectorUint32
+	for i := 0; i < 200; i++ {
+		sv := genSpreadSparseVector(rnd, 1+rnd.Intn(20), 500)
+		if id := ix.Add(sv); id != i {
+			t.Fatalf("id is %d, expected %d", id, i)
+		}
+		vectors = append(vectors, sv)
+	}
+	if ix.Len() != 200 || ix.Vector(7) != vectors[7] {
+		t.Fatalf("index not as expected")
+	}
+
+	for q := 0; q < 20; q++ {
+		query := genSpreadSparseVector(rnd, 1+rnd.Intn(20), 500)
+		ids, dots := ix.Dots(query)
+		k := 0
+		for id, sv := range vectors {
+			shares := intersectionSize(query.indices, sv.indices) > 0
+			if !shares {
+				continue
+			}
+			if k >= len(ids) || ids[k] != id {
+				t.Fatalf("Query %d. Vector %d missing", q, id)
+			}
+			if exp := query.Dot(sv); dots[k] != exp {
+				t.Errorf("Query %d. Dot with %d is %f, expected %f", q, id, dots[k], exp)
+			}
+			k++
+		}
+		if k != len(ids) {
+			t.Errorf("Query %d. Have %d ids, expected %d", q, len(ids), k)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package sparsevector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Vectors marshal to JSON as an object mapping each index to its value, for
+// example {"1":4,"3":5}. Keys are written in index order.
+//
+// Vectors can also be marshalled in parallel-array form by wrapping them in
+// ArrayJSON, for example {"indices":[1,3],"values":[4,5]}. GenSparseVectors
+// also record the type of their index in this form, so that they round-trip
+// exactly.
+//
+// UnmarshalJSON accepts either form, and sorts the decoded vector by index.
+
+// Names for the index types in parallel-array JSON
+const (
+	jsonTypeUint32 = "uint32"
+	jsonTypeInt    = "int"
+	jsonTypeString = "string"
+)
+
+// ArrayJSON wraps a vector so that it is marshalled to JSON in parallel-array
+// form. To unmarshal, set Vector to a pointer to the type of vector you want.
+type ArrayJSON struct {
+	Vector Vector
+}
+
+type jsonArrays struct {
+	Type    string          `json:"type,omitempty"`
+	Indices json.RawMessage `json:"indices"`
+	Values  []Value         `json:"values"`
+}
+
+// MarshalJSON marshals the wrapped vector in parallel-array form
+func (a ArrayJSON) MarshalJSON() ([]byte, error) {
+	switch v := a.Vector.(type) {
+	case *SparseVectorUint32:
+		return marshalJSONArrays("", Uint32Index(v.indices), v.values)
+	case *MapSparseVector:
+		indices, values := v.sorted()
+		return marshalJSONArrays("", Uint32Index(indices), values)
+	case *GenSparseVector:
+		typ, err := jsonIndexType(v.index)
+		if err != nil {
+			return nil, err
+		}
+		return marshalJSONArrays(typ, v.index, v.values)
+	}
+	return nil, fmt.Errorf("sparsevector: cannot marshal %T to JSON", a.Vector)
+}
+
+// UnmarshalJSON unmarshals into the wrapped vector.
+func (a *ArrayJSON) UnmarshalJSON(data []byte) error {
+	u, ok := a.Vector.(json.Unmarshaler)
+	if !ok {
+		return fmt.Errorf("sparsevector: cannot unmarshal JSON into %T", a.Vector)
+	}
+	return u.UnmarshalJSON(data)
+}
+
+// MarshalJSON marshals the vector as a JSON object mapping indices to values
+func (sv *SparseVectorUint32) MarshalJSON() ([]byte, error) {
+	return marshalJSONObject(Uint32Index(sv.indices), sv.values)
+}
+
+// UnmarshalJSON unmarshals the vector from either JSON form
+func (sv *SparseVectorUint32) UnmarshalJSON(data []byte) error {
+	index, values, err := unmarshalJSONVector(data, jsonTypeUint32)
+	if err != nil {
+		return err
+	}
+	*sv = *NewSparseVectorUint32([]uint32(index.(Uint32Index)), values)
+	return nil
+}
+
+// MarshalJSON marshals the vector as a JSON object mapping indices to values
+func (m *MapSparseVector) MarshalJSON() ([]byte, error) {
+	indices, values := m.sorted()
+	return marshalJSONObject(Uint32Index(indices), values)
+}
+
+// UnmarshalJSON unmarshals the vector from either JSON form
+func (m *MapSparseVector) UnmarshalJSON(data []byte) error {
+	index, values, err := unmarshalJSONVector(data, jsonTypeUint32)
+	if err != nil {
+		return err
+	}
+	*m = *NewMapSparseVector([]uint32(index.(Uint32Index)), values)
+	return nil
+}
+
+// MarshalJSON marshals the vector as a JSON object mapping indices to values.
+// The index must be a Uint32Index, IntIndex or StringIndex.
+func (sv *GenSparseVector) MarshalJSON() ([]byte, error) {
+	return marshalJSONObject(sv.index, sv.values)
+}
+
+// UnmarshalJSON unmarshals the vector from either JSON form.
+//
+// If sv already has an index, the decoded vector has an index of the same
+// type. Otherwise the type is taken from the "type" field in parallel-array
+// form, and object form decodes to a StringIndex.
+func (sv *GenSparseVector) UnmarshalJSON(data []byte) error {
+	var typ string
+	if sv.index != nil {
+		var err error
+		if typ, err = jsonIndexType(sv.index); err != nil {
+			return err
+		}
+	}
+	index, values, err := unmarshalJSONVector(data, typ)
+	if err != nil {
+		return err
+	}
+	*sv = *NewGenSparseVector(index, values)
+	return nil
+}
+
+func jsonIndexType(index VectorIndex) (string, error) {
+	switch index.(type) {
+	case Uint32Index:
+		return jsonTypeUint32, nil
+	case IntIndex:
+		return jsonTypeInt, nil
+	case StringIndex:
+		return jsonTypeString, nil
+	}
+	return "", ErrUnsupportedIndex
+}
+
+func marshalJSONObject(index VectorIndex, values []Value) ([]byte, error) {
+	buf := make([]byte, 0, 2+len(values)*12)
+	buf = append(buf, '{')
+	for i, v := range values {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		switch index := index.(type) {
+		case Uint32Index:
+			buf = append(buf, '"')
+			buf = strconv.AppendUint(buf, uint64(index[i]), 10)
+			buf = append(buf, '"')
+		case IntIndex:
+			buf = append(buf, '"')
+			buf = strconv.AppendInt(buf, int64(index[i]), 10)
+			buf = append(buf, '"')
+		case StringIndex:
+			key, err := json.Marshal(index[i])
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, key...)
+		default:
+			return nil, ErrUnsupportedIndex
+		}
+		buf = append(buf, ':')
+		var err error
+		if buf, err = appendJSONValue(buf, v); err != nil {
+			return nil, err
+		}
+	}
+	return append(buf, '}'), nil
+}
+
+func appendJSONValue(buf []byte, v Value) ([]byte, error) {
+	f := float64(v)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("sparsevector: cannot represent value %v in JSON", v)
+	}
+	return strconv.AppendFloat(buf, f, 'g', -1, 32), nil
+}
+
+func marshalJSONArrays(typ string, index VectorIndex, values []Value) ([]byte, error) {
+	if index.Len() != len(values) {
+		return nil, fmt.Errorf("sparsevector: %d indices but %d values", index.Len(), len(values))
+	}
+	indices := []byte("[]")
+	if index.Len() > 0 {
+		var err error
+		if indices, err = json.Marshal(index); err != nil {
+			return nil, err
+		}
+	}
+	if values == nil {
+		values = []Value{}
+	}
+	return json.Marshal(jsonArrays{Type: typ, Indices: indices, Values: values})
+}
+
+// unmarshalJSONVector decodes either JSON form. typ is the required index
+// type, or "" if any type is acceptable. The results are sorted by index, and
+// a repeated index is an error.
+func unmarshalJSONVector(data []byte, typ string) (VectorIndex, []Value, error) {
+	index, values, err := unmarshalJSONUnsorted(data, typ)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Sort(genSparseVectorSort{&GenSparseVector{index: index, values: values}})
+	for i := 1; i < index.Len(); i++ {
+		if !index.Less(i-1, i) {
+			return nil, nil, fmt.Errorf("sparsevector: index %v repeated", index.GetAtLocation(i))
+		}
+	}
+	return index, values, nil
+}
+
+// unmarshalJSONUnsorted decodes either JSON form, without sorting
+func unmarshalJSONUnsorted(data []byte, typ string) (VectorIndex, []Value, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, err
+	}
+	if raw == nil {
+		return nil, nil, errors.New("sparsevector: cannot unmarshal JSON null into a vector")
+	}
+
+	if isJSONArrayForm(raw) {
+		return unmarshalJSONArrays(data, typ)
+	}
+
+	if typ == "" {
+		typ = jsonTypeString
+	}
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+
+	var index VectorIndex
+	switch typ {
+	case jsonTypeUint32:
+		ui := make(Uint32Index, len(keys))
+		for i, k := range keys {
+			v, err := strconv.ParseUint(k, 10, 32)
+			if err != nil {
+				return nil, nil, fmt.Errorf("sparsevector: bad uint32 index %q", k)
+			}
+			ui[i] = uint32(v)
+		}
+		index = ui
+	case jsonTypeInt:
+		ii := make(IntIndex, len(keys))
+		for i, k := range keys {
+			v, err := strconv.ParseInt(k, 10, strconv.IntSize)
+			if err != nil {
+				return nil, nil, fmt.Errorf("sparsevector: bad int index %q", k)
+			}
+			ii[i] = int(v)
+		}
+		index = ii
+	default:
+		index = StringIndex(keys)
+	}
+
+	values := make([]Value, len(keys))
+	for i, k := range keys {
+		if err := json.Unmarshal(raw[k], &values[i]); err != nil {
+			return nil, nil, fmt.Errorf("sparsevector: bad value for index %q. %w", k, err)
+		}
+	}
+	return index, values, nil
+}
+
+// isJSONArrayForm decides whether a JSON object is in parallel-array form. In
+// object form the values are all numbers, so we look for arrays.
+func isJSONArrayForm(raw map[string]json.RawMessage) bool {
+	indices, ok := raw["indices"]
+	if !ok || len(indices) == 0 || indices[0] != '[' {
+		return false
+	}
+	values, ok := raw["values"]
+	return ok && len(values) > 0 && values[0] == '['
+}
+
+func unmarshalJSONArrays(data []byte, typ string) (VectorIndex, []Value, error) {
+	var a jsonArrays
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case a.Type == "":
+	case typ == "":
+		typ = a.Type
+	case a.Type != typ:
+		return nil, nil, fmt.Errorf("sparsevector: cannot unmarshal %s index into %s index", a.Type, typ)
+	}
+	if typ == "" {
+		// Guess from the first index
+		typ = jsonTypeInt
+		for _, c := range a.Indices {
+			if c == '"' {
+				typ = jsonTypeString
+				break
+			}
+			if c != '[' && c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				break
+			}
+		}
+	}
+
+	var index VectorIndex
+	var err error
+	switch typ {
+	case jsonTypeUint32:
+		var ui Uint32Index
+		err = json.Unmarshal(a.Indices, &ui)
+		index = ui
+	case jsonTypeInt:
+		var ii IntIndex
+		err = json.Unmarshal(a.Indices, &ii)
+		index = ii
+	case jsonTypeString:
+		var si StringIndex
+		err = json.Unmarshal(a.Indices, &si)
+		index = si
+	default:
+		return nil, nil, fmt.Errorf("sparsevector: unknown index type %q", typ)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if index.Len() != len(a.Values) {
+		return nil, nil, fmt.Errorf("sparsevector: %d indices but %d values", index.Len(), len(a.Values))
+	}
+	return index, a.Values, nil
+}
+
+// Assert our vectors implement the JSON interfaces
+var (
+	_ json.Marshaler   = (*SparseVectorUint32)(nil)
+	_ json.Unmarshaler = (*SparseVectorUint32)(nil)
+	_ json.Marshaler   = (*MapSparseVector)(nil)
+	_ json.Unmarshaler = (*MapSparseVector)(nil)
+	_ json.Marshaler   = (*GenSparseVector)(nil)
+	_ json.Unmarshaler = (*GenSparseVector)(nil)
+	_ json.Marshaler   = ArrayJSON{}
+	_ json.Unmarshaler = (*ArrayJSON)(nil)
+)
@@ -0,0 +1,194 @@
+package sparsevector
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestJSONSparseVectorUint32(t *testing.T) {
+	sv := NewSparseVectorUint32([]uint32{10, 2, 3}, []Value{4, 5.5, -6})
+
+	data, err := json.Marshal(sv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"2":5.5,"3":-6,"10":4}` {
+		t.Errorf("JSON not as expected. Have %s", data)
+	}
+
+	var sv2 SparseVectorUint32
+	if err := json.Unmarshal(data, &sv2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sv, &sv2) {
+		t.Errorf("Round trip not as expected. Have %v", sv2)
+	}
+
+	data, err = json.Marshal(ArrayJSON{sv})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"indices":[2,3,10],"values":[5.5,-6,4]}` {
+		t.Errorf("Array JSON not as expected. Have %s", data)
+	}
+
+	var sv3 SparseVectorUint32
+	if err := json.Unmarshal(data, &ArrayJSON{&sv3}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sv, &sv3) {
+		t.Errorf("Array round trip not as expected. Have %v", sv3)
+	}
+}
+
+func TestJSONMapSparseVector(t *testing.T) {
+	m := NewMapSparseVector([]uint32{10, 2, 3}, []Value{4, 5, 6})
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"2":5,"3":6,"10":4}` {
+		t.Errorf("JSON not as expected. Have %s", data)
+	}
+
+	var m2 MapSparseVector
+	if err := json.Unmarshal(data, &m2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, &m2) {
+		t.Errorf("Round trip not as expected. Have %v", m2)
+	}
+
+	var m3 MapSparseVector
+	if err := json.Unmarshal([]byte(`{"indices":[3,2,10],"values":[6,5,4]}`), &m3); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, &m3) {
+		t.Errorf("Array decode not as expected. Have %v", m3)
+	}
+}
+
+func TestJSONGenSparseVector(t *testing.T) {
+	tests := []struct {
+		v      *GenSparseVector
+		object string
+		arrays string
+	}{{
+		v:      NewGenSparseVector(StringIndex{"b", "a", "indices"}, []Value{1, 2, 3}),
+		object: `{"a":2,"b":1,"indices":3}`,
+		arrays: `{"type":"string","indices":["a","b","indices"],"values":[2,1,3]}`,
+	}, {
+		v:      NewGenSparseVector(IntIndex{10, -2}, []Value{1, 2}),
+		object: `{"-2":2,"10":1}`,
+		arrays: `{"type":"int","indices":[-2,10],"values":[2,1]}`,
+	}, {
+		v:      NewGenSparseVector(Uint32Index{10, 2}, []Value{1, 2}),
+		object: `{"2":2,"10":1}`,
+		arrays: `{"type":"uint32","indices":[2,10],"values":[2,1]}`,
+	}, {
+		v:      NewGenSparseVector(Uint32Index{}, []Value{}),
+		object: `{}`,
+		arrays: `{"type":"uint32","indices":[],"values":[]}`,
+	},
+	}
+
+	for i, test := range tests {
+		data, err := json.Marshal(test.v)
+		if err != nil {
+			t.Fatalf("Test %d. %v", i, err)
+		}
+		if string(data) != test.object {
+			t.Errorf("Test %d. JSON not as expected. Have %s", i, data)
+		}
+
+		// Object form needs to be told the index type
+		v := &GenSparseVector{index: test.v.index.New(0)}
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatalf("Test %d. %v", i, err)
+		}
+		if !reflect.DeepEqual(test.v, v) {
+			t.Errorf("Test %d. Round trip not as expected. Have %v", i, v)
+		}
+
+		data, err = json.Marshal(ArrayJSON{test.v})
+		if err != nil {
+			t.Fatalf("Test %d. %v", i, err)
+		}
+		if string(data) != test.arrays {
+			t.Errorf("Test %d. Array JSON not as expected. Have %s", i, data)
+		}
+
+		// Array form records the index type
+		v = &GenSparseVector{}
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatalf("Test %d. %v", i, err)
+		}
+		if !reflect.DeepEqual(test.v, v) {
+			t.Errorf("Test %d. Array round trip not as expected. Have %v", i, v)
+		}
+	}
+}
+
+func TestJSONGenSparseVectorDefaults(t *testing.T) {
+	var v GenSparseVector
+	if err := json.Unmarshal([]byte(`{"b":1,"a":2}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(NewGenSparseVector(StringIndex{"a", "b"}, []Value{2, 1}), &v) {
+		t.Errorf("Object decode not as expected. Have %v", v)
+	}
+
+	v = GenSparseVector{}
+	if err := json.Unmarshal([]byte(`{"indices":[3, 1],"values":[2,1]}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(NewGenSparseVector(IntIndex{1, 3}, []Value{1, 2}), &v) {
+		t.Errorf("Array decode not as expected. Have %v", v)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	tests := []string{
+		`null`,
+		`[]`,
+		`{"a":1}`,
+		`{"-1":1}`,
+		`{"4294967296":1}`,
+		`{"1":"x"}`,
+		`{"indices":[1,2],"values":[1]}`,
+		`{"indices":["a"],"values":[1]}`,
+		`{"type":"string","indices":[1],"values":[1]}`,
+		`{"indices":[1,1],"values":[1,2]}`,
+		`{"1":1,"01":2}`,
+	}
+
+	for i, test := range tests {
+		var sv SparseVectorUint32
+		if err := json.Unmarshal([]byte(test), &sv); err == nil {
+			t.Errorf("Test %d. Expected an error decoding %s", i, test)
+		}
+	}
+
+	repeated := []struct {
+		data string
+		v    interface{}
+	}{
+		{`{"indices":[1,1],"values":[1,2]}`, &MapSparseVector{}},
+		{`{"1":1,"01":2}`, &MapSparseVector{}},
+		{`{"indices":[2,1,2],"values":[1,2,3]}`, &GenSparseVector{}},
+		{`{"indices":["a","a"],"values":[1,2]}`, &GenSparseVector{}},
+	}
+
+	for i, test := range repeated {
+		if err := json.Unmarshal([]byte(test.data), test.v); err == nil {
+			t.Errorf("Test %d. Expected an error decoding %s into %T", i, test.data, test.v)
+		}
+	}
+
+	if _, err := json.Marshal(&SparseVectorUint32{indices: []uint32{1}, values: []Value{Value(math.Inf(1))}}); err == nil {
+		t.Errorf("Expected an error encoding infinity")
+	}
+}
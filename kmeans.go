@@ -0,0 +1,366 @@
+package sparsevector
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// KMeans clusters vectors by cosine similarity using spherical k-means. Each
+// cluster has a centroid of magnitude 1, and each vector is assigned to the
+// centroid it has the highest cosine with. Centroids are then recalculated as
+// the normalized sum of the normalized vectors assigned to them, and the
+// process repeats until it converges.
+//
+// Initial centroids are chosen by k-means++ seeding, using a random source
+// seeded with Seed, so results are deterministic for a given Seed.
+type KMeans struct {
+	// K is the number of clusters
+	K int
+	// MaxIter is the maximum number of iterations. If it is zero the default
+	// of 100 is used.
+	MaxIter int
+	// Tolerance stops the iterations when the objective, the total cosine of
+	// the vectors with their centroids, improves by less than this fraction.
+	// Iterations always stop when no vector changes cluster. If it is zero
+	// the default of 1e-4 is used. Set it negative to stop only when no
+	// vector changes cluster.
+	Tolerance Value
+	// MaxCentroidSize is the maximum number of entries kept in each centroid.
+	// The entries with the largest absolute values are kept. If it is zero
+	// centroids are not pruned, which is the default.
+	MaxCentroidSize int
+	// Workers is the number of goroutines used to assign vectors to
+	// clusters. If it is zero GOMAXPROCS goroutines are used, which is the
+	// default.
+	Workers int
+	// Seed seeds the random choice of initial centroids. NewKMeans sets it
+	// to 1.
+	Seed int64
+}
+
+// KMeansResult is the result of clustering with KMeans
+type KMeansResult struct {
+	// Centroids are the centroids of the clusters. They have magnitude 1.
+	Centroids []*SparseVectorUint32
+	// Labels gives the cluster of each vector
+	Labels []int
+	// Objective is the total cosine of the vectors with their centroids
+	Objective Value
+	// Iterations is the number of iterations run
+	Iterations int
+	// Converged is true if the iterations stopped because the clustering
+	// converged, rather than because they reached MaxIter
+	Converged bool
+}
+
+// NewKMeans creates a KMeans for k clusters with the default settings
+func NewKMeans(k int) *KMeans {
+	return &KMeans{
+		K:         k,
+		MaxIter:   100,
+		Tolerance: 1e-4,
+		Seed:      1,
+	}
+}
+
+// Fit clusters the vectors. The vectors are not modified. Vectors with no
+// entries have a cosine of zero with every centroid.
+func (km *KMeans) Fit(vectors []*SparseVectorUint32) (*KMeansResult, error) {
+	if km.K < 1 {
+		return nil, errors.New("sparsevector: KMeans needs at least one cluster")
+	}
+	if len(vectors) < km.K {
+		return nil, fmt.Errorf("sparsevector: KMeans cannot make %d clusters from %d vectors", km.K, len(vectors))
+	}
+
+	rnd := rand.New(rand.NewSource(km.Seed))
+	res := &KMeansResult{
+		Centroids: km.seed(vectors, rnd),
+		Labels:    make([]int, len(vectors)),
+	}
+	for i := range res.Labels {
+		res.Labels[i] = -1
+	}
+	maxIter := km.MaxIter
+	if maxIter == 0 {
+		maxIter = 100
+	}
+	tolerance := km.Tolerance
+	if tolerance == 0 {
+		tolerance = 1e-4
+	}
+	cos := make([]Value, len(vectors))
+	acc := newCentroidAccumulator(vectors)
+	// Calculate the magnitudes now, as they are cached and assignment runs
+	// in parallel
+	for _, sv := range vectors {
+		sv.Mag()
+	}
+
+	for {
+		res.Iterations++
+		changed := assignClusters(res.Centroids, vectors, res.Labels, cos, km.Workers)
+
+		var objective Value
+		for _, c := range cos {
+			objective += c
+		}
+		improvement := objective - res.Objective
+		res.Objective = objective
+		if changed == 0 || (tolerance >= 0 && res.Iterations > 1 && improvement <= tolerance*abs(objective)) {
+			res.Converged = true
+			break
+		}
+		if res.Iterations >= maxIter {
+			// Stop before updating the centroids, so the labels and
+			// objective match them
+			break
+		}
+
+		res.Centroids = km.updateCentroids(vectors, res.Labels, cos, acc)
+	}
+	return res, nil
+}
+
+func abs(v Value) Value {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// seed picks the initial centroids using k-means++. Each centroid after the
+// first is chosen with probability proportional to its cosine distance from
+// the nearest centroid chosen so far.
+func (km *KMeans) seed(vectors []*SparseVectorUint32, rnd *rand.Rand) []*SparseVectorUint32 {
+	centroids := make([]*SparseVectorUint32, 0, km.K)
+	chosen := make([]bool, len(vectors))
+	dist := make([]float64, len(vectors))
+	for i := range dist {
+		dist[i] = 1
+	}
+
+	next := rnd.Intn(len(vectors))
+	for {
+		chosen[next] = true
+		c := normalizedCopy(vectors[next])
+		centroids = append(centroids, c)
+		if len(centroids) == km.K {
+			return centroids
+		}
+
+		var total float64
+		for i, sv := range vectors {
+			if d := 1 - float64(cosUnit(sv, c)); d < dist[i] {
+				dist[i] = d
+			}
+			if dist[i] < 0 || chosen[i] {
+				dist[i] = 0
+			}
+			total += dist[i]
+		}
+
+		if total <= 0 {
+			// Every remaining vector is identical to a centroid, so pick any
+			// that hasn't been picked
+			next = rnd.Intn(len(vectors) - len(centroids))
+			for i := range vectors {
+				if !chosen[i] {
+					if next == 0 {
+						next = i
+						break
+					}
+					next--
+				}
+			}
+			continue
+		}
+
+		target := rnd.Float64() * total
+		next = -1
+		for i, d := range dist {
+			if d > 0 {
+				next = i
+				if target -= d; target < 0 {
+					break
+				}
+			}
+		}
+	}
+}
+
+// cosUnit calculates the cosine of a vector with a vector of magnitude 1
+func cosUnit(sv, unit *SparseVectorUint32) Value {
+	mag := sv.Mag()
+	if mag == 0 {
+		return 0
+	}
+	return sv.Dot(unit) / mag
+}
+
+// normalizedCopy returns a copy of a vector scaled to magnitude 1
+func normalizedCopy(sv *SparseVectorUint32) *SparseVectorUint32 {
+	c := &SparseVectorUint32{
+		indices: append([]uint32(nil), sv.indices...),
+		values:  append([]Value(nil), sv.values...),
+	}
+	normalize(c)
+	return c
+}
+
+// assignClusters assigns each vector to the centroid it has the highest
+// cosine with, in parallel. It returns the number of vectors whose label
+// changed.
+func assignClusters(centroids, vectors []*SparseVectorUint32, labels []int, cos []Value, workers int) int {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	chunk := (len(vectors) + workers - 1) / workers
+	changed := make([]int, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		start, end := w*chunk, (w+1)*chunk
+		if end > len(vectors) {
+			end = len(vectors)
+		}
+		if start >= end {
+			break
+		}
+		wg.Add(1)
+		go func(w, start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				label, c := nearestCentroid(centroids, vectors[i])
+				if label != labels[i] {
+					labels[i] = label
+					changed[w]++
+				}
+				cos[i] = c
+			}
+		}(w, start, end)
+	}
+	wg.Wait()
+
+	var total int
+	for _, c := range changed {
+		total += c
+	}
+	return total
+}
+
+// nearestCentroid finds the centroid of magnitude 1 with the highest cosine
+// with sv. Ties go to the lowest numbered centroid.
+func nearestCentroid(centroids []*SparseVectorUint32, sv *SparseVectorUint32) (int, Value) {
+	best, bestCos := 0, Value(0)
+	for c, centroid := range centroids {
+		if cos := cosUnit(sv, centroid); c == 0 || cos > bestCos {
+			best, bestCos = c, cos
+		}
+	}
+	return best, bestCos
+}
+
+// newCentroidAccumulator creates an accumulator for summing vectors into
+// centroids. The workspace is dense unless the indices are spread very
+// widely.
+func newCentroidAccumulator(vectors []*SparseVectorUint32) *Accumulator {
+	var max uint32
+	var total int
+	for _, sv := range vectors {
+		if l := len(sv.indices); l > 0 && sv.indices[l-1] > max {
+			max = sv.indices[l-1]
+		}
+		total += len(sv.indices)
+	}
+	if int64(max) < 16*int64(total)+1024 {
+		return NewAccumulator(int(max) + 1)
+	}
+	return NewAccumulator(0)
+}
+
+// updateCentroids recalculates the centroids from the labels. A cluster that
+// has lost all its vectors takes the vector that is furthest from its own
+// centroid instead.
+func (km *KMeans) updateCentroids(vectors []*SparseVectorUint32, labels []int, cos []Value, acc *Accumulator) []*SparseVectorUint32 {
+	members := make([][]int, km.K)
+	for i, label := range labels {
+		members[label] = append(members[label], i)
+	}
+
+	centroids := make([]*SparseVectorUint32, km.K)
+	sizes := make([]int, km.K)
+	for c := range centroids {
+		sizes[c] = len(members[c])
+		if sizes[c] == 0 {
+			continue
+		}
+		for _, i := range members[c] {
+			if mag := vectors[i].Mag(); mag != 0 {
+				acc.AddScaled(vectors[i], 1/mag)
+			}
+		}
+		centroids[c] = prune(acc.Sum(), km.MaxCentroidSize)
+		normalize(centroids[c])
+	}
+
+	for c := range centroids {
+		if centroids[c] != nil {
+			continue
+		}
+		worst := -1
+		for i, label := range labels {
+			if sizes[label] > 1 && (worst < 0 || cos[i] < cos[worst]) {
+				worst = i
+			}
+		}
+		if worst < 0 {
+			// Can't happen with at least K vectors, but keep the centroid
+			// valid
+			centroids[c] = &SparseVectorUint32{}
+			continue
+		}
+		sizes[labels[worst]]--
+		sizes[c]++
+		labels[worst] = c
+		cos[worst] = 1
+		centroids[c] = prune(normalizedCopy(vectors[worst]), km.MaxCentroidSize)
+		normalize(centroids[c])
+	}
+	return centroids
+}
+
+// prune keeps the max entries of sv with the largest absolute values. If max
+// is zero or sv is already small enough sv is returned unchanged.
+func prune(sv *SparseVectorUint32, max int) *SparseVectorUint32 {
+	if max <= 0 || len(sv.indices) <= max {
+		return sv
+	}
+	order := make([]int, len(sv.indices))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		vi, vj := abs(sv.values[order[i]]), abs(sv.values[order[j]])
+		if vi != vj {
+			return vi > vj
+		}
+		return order[i] < order[j]
+	})
+	order = order[:max]
+	sort.Ints(order)
+
+	out := &SparseVectorUint32{
+		indices: make([]uint32, max),
+		values:  make([]Value, max),
+	}
+	for i, o := range order {
+		out.indices[i] = sv.indices[o]
+		out.values[i] = sv.values[o]
+	}
+	return out
+}
@@ -0,0 +1,242 @@
+package sparsevector
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func BenchmarkKMeans(b *testing.B) {
+	vectors, _ := genClusterTestVectors(rand.New(rand.NewSource(1)), 10, 100)
+	km := NewKMeans(10)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		km.Fit(vectors)
+	}
+}
+
+// genClusterTestVectors generates n vectors for each of k topics. Each topic
+// uses its own range of 100 indices, with a little noise from other topics.
+func genClusterTestVectors(rnd *rand.Rand, k, n int) ([]*SparseVectorUint32, []int) {
+	var vectors []*SparseVectorUint32
+	var topics []int
+	for i := 0; i < k*n; i++ {
+		topic := rnd.Intn(k)
+		var indices []uint32
+		var values []Value
+		for _, j := range rnd.Perm(100)[:20] {
+			indices = append(indices, uint32(topic*100+j))
+			values = append(values, Value(1+rnd.Intn(5)))
+		}
+		noise := uint32(rnd.Intn(k*100)) | 1<<20
+		indices = append(indices, noise)
+		values = append(values, 1)
+		vectors = append(vectors, NewSparseVectorUint32(indices, values))
+		topics = append(topics, topic)
+	}
+	return vectors, topics
+}
+
+// checkClustersMatch checks that clusters and topics partition the vectors
+// the same way
+func checkClustersMatch(t *testing.T, labels, topics []int) {
+	t.Helper()
+	clusterTopic := make(map[int]int)
+	topicCluster := make(map[int]int)
+	for i, label := range labels {
+		if topic, ok := clusterTopic[label]; ok && topic != topics[i] {
+			t.Errorf("cluster %d has topics %d and %d", label, topic, topics[i])
+			return
+		}
+		if cluster, ok := topicCluster[topics[i]]; ok && cluster != label {
+			t.Errorf("topic %d is in clusters %d and %d", topics[i], cluster, label)
+			return
+		}
+		clusterTopic[label] = topics[i]
+		topicCluster[topics[i]] = label
+	}
+}
+
+func TestKMeans(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	vectors, topics := genClusterTestVectors(rnd, 4, 30)
+
+	km := NewKMeans(4)
+	km.Workers = 3
+	res, err := km.Fit(vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Converged || res.Iterations >= km.MaxIter {
+		t.Errorf("did not converge in %d iterations", res.Iterations)
+	}
+	checkClustersMatch(t, res.Labels, topics)
+
+	var objective Value
+	for i, sv := range vectors {
+		objective += sv.Cos(res.Centroids[res.Labels[i]])
+	}
+	if math.Abs(float64(objective-res.Objective)) > 1e-3 {
+		t.Errorf("Objective is %f, expected %f", res.Objective, objective)
+	}
+	for c, centroid := range res.Centroids {
+		if math.Abs(float64(centroid.Mag())-1) > 1e-6 {
+			t.Errorf("centroid %d has magnitude %f", c, centroid.Mag())
+		}
+	}
+
+	// The same seed gives the same result, whatever the number of workers
+	km.Workers = 1
+	res2, err := km.Fit(vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Labels, res2.Labels) || res.Objective != res2.Objective {
+		t.Errorf("results differ with a different number of workers")
+	}
+
+	// Pruned centroids
+	km.MaxCentroidSize = 20
+	res, err = km.Fit(vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for c, centroid := range res.Centroids {
+		if len(centroid.indices) > 20 {
+			t.Errorf("centroid %d has %d entries", c, len(centroid.indices))
+		}
+		if err := centroid.Validate(); err != nil {
+			t.Errorf("centroid %d invalid. %v", c, err)
+		}
+	}
+	checkClustersMatch(t, res.Labels, topics)
+}
+
+func TestKMeansMaxIter(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var vectors []*SparseVectorUint32
+	for i := 0; i < 1000; i++ {
+		vectors = append(vectors, genSpreadSparseVector(rnd, 1+rnd.Intn(20), 200))
+	}
+
+	// When the iterations stop at MaxIter the labels and objective still
+	// match the centroids returned
+	for _, maxIter := range []int{1, 2, 3} {
+		km := NewKMeans(10)
+		km.MaxIter = maxIter
+		res, err := km.Fit(vectors)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Iterations > maxIter {
+			t.Errorf("MaxIter %d. Ran %d iterations", maxIter, res.Iterations)
+		}
+		var objective Value
+		for i, sv := range vectors {
+			label, cos := nearestCentroid(res.Centroids, sv)
+			if label != res.Labels[i] {
+				t.Errorf("MaxIter %d. Vector %d has label %d, but nearest centroid %d", maxIter, i, res.Labels[i], label)
+				break
+			}
+			objective += cos
+		}
+		if math.Abs(float64(objective-res.Objective)) > 1e-3 {
+			t.Errorf("MaxIter %d. Objective is %f, expected %f", maxIter, res.Objective, objective)
+		}
+	}
+}
+
+func TestKMeansZeroValue(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	vectors, _ := genClusterTestVectors(rnd, 4, 30)
+
+	// Zero fields take their defaults
+	res, err := (&KMeans{K: 4, Seed: 1}).Fit(vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, err := NewKMeans(4).Fit(vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Labels, exp.Labels) || res.Iterations != exp.Iterations || !res.Converged {
+		t.Errorf("zero value result differs. Have %d iterations", res.Iterations)
+	}
+}
+
+func TestKMeansEdgeCases(t *testing.T) {
+	// Only two distinct vectors for three clusters
+	vectors := []*SparseVectorUint32{
+		NewSparseVectorUint32([]uint32{1}, []Value{1}),
+		NewSparseVectorUint32([]uint32{1}, []Value{2}),
+		NewSparseVectorUint32([]uint32{2}, []Value{1}),
+		NewSparseVectorUint32([]uint32{2}, []Value{1}),
+	}
+	res, err := NewKMeans(3).Fit(vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Centroids) != 3 {
+		t.Errorf("have %d centroids", len(res.Centroids))
+	}
+	if res.Labels[0] != res.Labels[1] && res.Labels[2] != res.Labels[3] {
+		t.Errorf("labels not as expected. Have %v", res.Labels)
+	}
+	for i, label := range res.Labels {
+		if label < 0 || label >= 3 {
+			t.Errorf("vector %d has label %d", i, label)
+		}
+	}
+
+	if _, err := NewKMeans(5).Fit(vectors); err == nil {
+		t.Errorf("expected an error with too few vectors")
+	}
+	if _, err := NewKMeans(0).Fit(vectors); err == nil {
+		t.Errorf("expected an error with no clusters")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	sv := NewSparseVectorUint32([]uint32{1, 2, 3, 4, 5}, []Value{1, -5, 3, 2, 3})
+	if p := prune(sv, 3); !reflect.DeepEqual(NewSparseVectorUint32([]uint32{2, 3, 5}, []Value{-5, 3, 3}), p) {
+		t.Errorf("Pruned vector not as expected. Have %v", p)
+	}
+	if p := prune(sv, 0); p != sv {
+		t.Errorf("Vector pruned to 0 changed")
+	}
+}
+
+func TestKMeansNegativeTolerance(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var vectors []*SparseVectorUint32
+	for i := 0; i < 500; i++ {
+		vectors = append(vectors, genSpreadSparseVector(rnd, 1+rnd.Intn(20), 100))
+	}
+
+	// Pruning the centroids can make the objective worse, but with a
+	// negative tolerance the iterations only stop when no vector changes
+	// cluster
+	km := NewKMeans(10)
+	km.Tolerance = -1e-9
+	km.MaxCentroidSize = 2
+	km.MaxIter = 1000
+	res, err := km.Fit(vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Converged {
+		t.Fatalf("not converged after %d iterations", res.Iterations)
+	}
+
+	// So the centroids are what the labels give, and give the same labels
+	cos := make([]Value, len(vectors))
+	centroids := km.updateCentroids(vectors, res.Labels, cos, newCentroidAccumulator(vectors))
+	labels := append([]int(nil), res.Labels...)
+	if changed := assignClusters(centroids, vectors, labels, cos, 1); changed != 0 {
+		t.Errorf("%d vectors change cluster after %d iterations", changed, res.Iterations)
+	}
+}
@@ -0,0 +1,255 @@
+package sparsevector
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Similarity selects how KNN measures the similarity of two vectors when it
+// finds neighbours through an InvertedIndex
+type Similarity byte
+
+const (
+	// CosineSimilarity is the cosine of the vectors
+	CosineSimilarity Similarity = iota
+	// DotSimilarity is the dot product of the vectors
+	DotSimilarity
+	// JaccardSimilarity is the Jaccard similarity of the sets of indices
+	// present in the vectors, ignoring values
+	JaccardSimilarity
+)
+
+// SimilarityFunc measures the similarity of two vectors. Larger values are
+// more similar.
+type SimilarityFunc func(a, b *SparseVectorUint32) Value
+
+// Vote selects how the neighbours found by KNN vote for a class
+type Vote byte
+
+const (
+	// MajorityVote gives each neighbour one vote
+	MajorityVote Vote = iota
+	// WeightedVote gives each neighbour a vote equal to its similarity with
+	// the query. Neighbours with a similarity of zero or less don't vote.
+	WeightedVote
+)
+
+// KNN is a k-nearest-neighbour classifier. It holds labelled example vectors,
+// and classifies a query by the labels of the K examples most similar to it.
+//
+// Examples are held in an InvertedIndex, so only examples that share an index
+// with a query are candidates to be its neighbours. Examples with nothing in
+// common with the query have a similarity of zero by every Similarity, so are
+// never neighbours. This means a query can have fewer than K neighbours. If
+// SimilarityFunc is set every example is compared with the query instead.
+//
+// Examples can be SparseVectorUint32s added with Add, or GenSparseVectors
+// added with AddGen, which are converted with a Vocabulary. A KNN can't hold
+// both. Like the vectors it holds, a KNN is not safe for concurrent use.
+//
+// The zero value is ready to use once K is set.
+type KNN struct {
+	// K is the number of neighbours that vote
+	K int
+	// Similarity is how neighbours are found. The default is CosineSimilarity.
+	Similarity Similarity
+	// SimilarityFunc, if set, is used instead of Similarity. It is called
+	// for every example, so is much slower.
+	SimilarityFunc SimilarityFunc
+	// Vote is how neighbours vote. The default is MajorityVote.
+	Vote Vote
+
+	index   *InvertedIndex
+	labels  []int
+	classes int
+	// vocab converts GenSparseVectors. It is nil unless AddGen is used.
+	vocab *Vocabulary
+}
+
+// NewKNN creates a KNN classifier where k neighbours vote, using
+// CosineSimilarity and MajorityVote
+func NewKNN(k int) *KNN {
+	return &KNN{K: k}
+}
+
+// Add adds an example with a class label. Labels are numbered from 0. The
+// vector must not be modified while the KNN holds it.
+func (knn *KNN) Add(sv *SparseVectorUint32, label int) error {
+	if knn.vocab != nil {
+		return errors.New("sparsevector: KNN holds GenSparseVector examples")
+	}
+	return knn.add(sv, label)
+}
+
+// AddGen adds a GenSparseVector example with a class label. Labels are
+// numbered from 0.
+func (knn *KNN) AddGen(gsv *GenSparseVector, label int) error {
+	if knn.vocab == nil {
+		if knn.Len() > 0 {
+			return errors.New("sparsevector: KNN holds SparseVectorUint32 examples")
+		}
+		knn.vocab = NewVocabulary()
+	}
+	if label < 0 {
+		return fmt.Errorf("sparsevector: bad KNN label %d", label)
+	}
+	knn.vocab.AddVector(gsv)
+	return knn.add(knn.vocab.Encode(gsv), label)
+}
+
+func (knn *KNN) add(sv *SparseVectorUint32, label int) error {
+	if label < 0 {
+		return fmt.Errorf("sparsevector: bad KNN label %d", label)
+	}
+	if knn.index == nil {
+		knn.index = NewInvertedIndex()
+	}
+	knn.index.Add(sv)
+	knn.labels = append(knn.labels, label)
+	if label >= knn.classes {
+		knn.classes = label + 1
+	}
+	return nil
+}
+
+// Len returns the number of examples
+func (knn *KNN) Len() int { return len(knn.labels) }
+
+// Classes returns the number of classes, which is one more than the largest
+// label added
+func (knn *KNN) Classes() int { return knn.classes }
+
+// Neighbours finds the K examples most similar to query. It returns their
+// positions in the order they were added, most similar first, and their
+// similarities. Ties go to the example added first. It finds nothing if the
+// KNN holds GenSparseVector examples.
+func (knn *KNN) Neighbours(query *SparseVectorUint32) ([]int, []Value) {
+	if knn.vocab != nil {
+		return nil, nil
+	}
+	return knn.neighbours(query, query.Mag(), len(query.indices))
+}
+
+// NeighboursGen is Neighbours for a KNN holding GenSparseVector examples. It
+// finds nothing if the KNN holds SparseVectorUint32 examples. SimilarityFunc
+// is given the query without the keys no example has.
+func (knn *KNN) NeighboursGen(query *GenSparseVector) ([]int, []Value) {
+	if knn.vocab == nil {
+		return nil, nil
+	}
+	// Keys the examples don't have are dropped when encoding the query, but
+	// still count towards its magnitude and size
+	return knn.neighbours(knn.vocab.Encode(query), query.Mag(), len(query.values))
+}
+
+// neighbours finds the nearest examples to query, whose magnitude and number
+// of entries before encoding are mag and size
+func (knn *KNN) neighbours(query *SparseVectorUint32, mag Value, size int) ([]int, []Value) {
+	if knn.index == nil {
+		return nil, nil
+	}
+	var ids []int
+	var sims []Value
+	if knn.SimilarityFunc != nil {
+		ids = make([]int, knn.Len())
+		sims = make([]Value, len(ids))
+		for id := range ids {
+			ids[id] = id
+			sims[id] = knn.SimilarityFunc(query, knn.index.Vector(id))
+		}
+	} else {
+		ids, sims = knn.index.Dots(query)
+		for i, id := range ids {
+			switch knn.Similarity {
+			case CosineSimilarity:
+				if m := mag * knn.index.Vector(id).Mag(); m != 0 {
+					sims[i] /= m
+				}
+			case JaccardSimilarity:
+				indices := knn.index.Vector(id).indices
+				common := intersectionSize(query.indices, indices)
+				sims[i] = Value(common) / Value(size+len(indices)-common)
+			}
+		}
+	}
+
+	order := make([]int, len(ids))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		si, sj := sims[order[i]], sims[order[j]]
+		if si != sj {
+			return si > sj
+		}
+		return order[i] < order[j]
+	})
+	if len(order) > knn.K {
+		order = order[:knn.K]
+	}
+
+	nids := make([]int, len(order))
+	nsims := make([]Value, len(order))
+	for i, o := range order {
+		nids[i] = ids[o]
+		nsims[i] = sims[o]
+	}
+	return nids, nsims
+}
+
+// Predict returns the probability of query being in each class, as the
+// share of the neighbours' votes for the class. If query has no neighbours,
+// or no neighbour votes, every probability is zero.
+func (knn *KNN) Predict(query *SparseVectorUint32) []Value {
+	return knn.probabilities(knn.Neighbours(query))
+}
+
+// PredictGen is Predict for a KNN holding GenSparseVector examples
+func (knn *KNN) PredictGen(query *GenSparseVector) []Value {
+	return knn.probabilities(knn.NeighboursGen(query))
+}
+
+func (knn *KNN) probabilities(ids []int, sims []Value) []Value {
+	probs := make([]Value, knn.classes)
+	var total Value
+	for i, id := range ids {
+		vote := Value(1)
+		if knn.Vote == WeightedVote {
+			if sims[i] <= 0 {
+				continue
+			}
+			vote = sims[i]
+		}
+		probs[knn.labels[id]] += vote
+		total += vote
+	}
+	if total > 0 {
+		for c := range probs {
+			probs[c] /= total
+		}
+	}
+	return probs
+}
+
+// Classify returns the most probable class for query, and its probability.
+// Ties go to the lowest numbered class. If query has no neighbours that
+// vote it returns -1.
+func (knn *KNN) Classify(query *SparseVectorUint32) (int, Value) {
+	return mostProbable(knn.Predict(query))
+}
+
+// ClassifyGen is Classify for a KNN holding GenSparseVector examples
+func (knn *KNN) ClassifyGen(query *GenSparseVector) (int, Value) {
+	return mostProbable(knn.PredictGen(query))
+}
+
+func mostProbable(probs []Value) (int, Value) {
+	best, bestProb := -1, Value(0)
+	for c, p := range probs {
+		if p > bestProb {
+			best, bestProb = c, p
+		}
+	}
+	return best, bestProb
+}
@@ -0,0 +1,293 @@
+package sparsevector
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func BenchmarkKNNClassify(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	vectors, topics := genClusterTestVectors(rnd, 10, 1000)
+	knn := NewKNN(10)
+	for i, sv := range vectors {
+		knn.Add(sv, topics[i])
+	}
+	queries, _ := genClusterTestVectors(rnd, 10, 10)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		knn.Classify(queries[i%len(queries)])
+	}
+}
+
+func TestKNN(t *testing.T) {
+	examples := []*SparseVectorUint32{
+		NewSparseVectorUint32([]uint32{1, 2}, []Value{1, 1}),
+		NewSparseVectorUint32([]uint32{1}, []Value{1}),
+		NewSparseVectorUint32([]uint32{2, 3}, []Value{1, 1}),
+		NewSparseVectorUint32([]uint32{3}, []Value{1}),
+		NewSparseVectorUint32([]uint32{4}, []Value{1}),
+	}
+	labels := []int{0, 0, 1, 1, 2}
+
+	tests := []struct {
+		k          int
+		similarity Similarity
+		simFunc    SimilarityFunc
+		vote       Vote
+		query      *SparseVectorUint32
+		ids        []int
+		probs      []Value
+	}{
+		{
+			k:     1,
+			query: NewSparseVectorUint32([]uint32{1}, []Value{1}),
+			ids:   []int{1},
+			probs: []Value{1, 0, 0},
+		},
+		{
+			k:     3,
+			query: NewSparseVectorUint32([]uint32{1, 3}, []Value{1, 1}),
+			ids:   []int{1, 3, 0},
+			probs: []Value{2.0 / 3, 1.0 / 3, 0},
+		},
+		{
+			// Only 3 examples share an index with the query
+			k:     10,
+			query: NewSparseVectorUint32([]uint32{2, 3}, []Value{1, 2}),
+			ids:   []int{2, 3, 0},
+			probs: []Value{1.0 / 3, 2.0 / 3, 0},
+		},
+		{
+			k:          2,
+			similarity: DotSimilarity,
+			query:      NewSparseVectorUint32([]uint32{2, 3}, []Value{1, 2}),
+			ids:        []int{2, 3},
+			probs:      []Value{0, 1, 0},
+		},
+		{
+			k:          2,
+			similarity: JaccardSimilarity,
+			query:      NewSparseVectorUint32([]uint32{1, 2, 3}, []Value{1, 1, 1}),
+			ids:        []int{0, 2},
+			probs:      []Value{0.5, 0.5, 0},
+		},
+		{
+			k:          3,
+			similarity: DotSimilarity,
+			vote:       WeightedVote,
+			query:      NewSparseVectorUint32([]uint32{1, 3}, []Value{1, 3}),
+			ids:        []int{2, 3, 0},
+			probs:      []Value{1.0 / 7, 6.0 / 7, 0},
+		},
+		{
+			// A negative similarity doesn't vote
+			k:          3,
+			similarity: DotSimilarity,
+			vote:       WeightedVote,
+			query:      NewSparseVectorUint32([]uint32{1, 4}, []Value{1, -1}),
+			ids:        []int{0, 1, 4},
+			probs:      []Value{1, 0, 0},
+		},
+		{
+			k:     3,
+			query: NewSparseVectorUint32([]uint32{5}, []Value{1}),
+			ids:   []int{},
+			probs: []Value{0, 0, 0},
+		},
+		{
+			// Every example is compared with SimilarityFunc
+			k: 2,
+			simFunc: func(a, b *SparseVectorUint32) Value {
+				return -a.Distance(b)
+			},
+			query: NewSparseVectorUint32([]uint32{5}, []Value{1}),
+			ids:   []int{1, 3},
+			probs: []Value{0.5, 0.5, 0},
+		},
+	}
+
+	for i, test := range tests {
+		knn := NewKNN(test.k)
+		knn.Similarity = test.similarity
+		knn.SimilarityFunc = test.simFunc
+		knn.Vote = test.vote
+		for j, sv := range examples {
+			if err := knn.Add(sv, labels[j]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if knn.Len() != 5 || knn.Classes() != 3 {
+			t.Fatalf("Test %d. Have %d examples and %d classes", i, knn.Len(), knn.Classes())
+		}
+
+		ids, _ := knn.Neighbours(test.query)
+		if !reflect.DeepEqual(ids, test.ids) {
+			t.Errorf("Test %d. Neighbours not as expected. Have %v", i, ids)
+		}
+		probs := knn.Predict(test.query)
+		if len(probs) != len(test.probs) {
+			t.Fatalf("Test %d. Have %d probabilities", i, len(probs))
+		}
+		for c, p := range probs {
+			if math.Abs(float64(p-test.probs[c])) > 1e-6 {
+				t.Errorf("Test %d. Probabilities not as expected. Have %v", i, probs)
+				break
+			}
+		}
+	}
+}
+
+func TestKNNRandom(t *testing.T) {
+	// Neighbours found through the index match a search of every example
+	rnd := rand.New(rand.NewSource(1))
+	var examples []*SparseVectorUint32
+	for i := 0; i < 300; i++ {
+		examples = append(examples, genSpreadSparseVector(rnd, 1+rnd.Intn(20), 500))
+	}
+
+	for _, similarity := range []Similarity{CosineSimilarity, DotSimilarity, JaccardSimilarity} {
+		knn := NewKNN(7)
+		knn.Similarity = similarity
+		for i, sv := range examples {
+			knn.Add(sv, i%4)
+		}
+
+		for q := 0; q < 20; q++ {
+			query := genSpreadSparseVector(rnd, 1+rnd.Intn(20), 500)
+			var expIDs []int
+			for id, sv := range examples {
+				if intersectionSize(query.indices, sv.indices) > 0 {
+					expIDs = append(expIDs, id)
+				}
+			}
+			sim := func(id int) Value {
+				switch similarity {
+				case CosineSimilarity:
+					return query.Cos(examples[id])
+				case DotSimilarity:
+					return query.Dot(examples[id])
+				}
+				return query.Jaccard(examples[id])
+			}
+			sort.SliceStable(expIDs, func(i, j int) bool { return sim(expIDs[i]) > sim(expIDs[j]) })
+			if len(expIDs) > 7 {
+				expIDs = expIDs[:7]
+			}
+
+			ids, sims := knn.Neighbours(query)
+			if len(ids) != len(expIDs) {
+				t.Fatalf("Similarity %d query %d. Have %d neighbours, expected %d", similarity, q, len(ids), len(expIDs))
+			}
+			for k, id := range ids {
+				// Allow for rounding changing the order of near ties
+				if exp := sim(expIDs[k]); math.Abs(float64(sims[k]-exp)) > 1e-5*math.Max(1, math.Abs(float64(exp))) {
+					t.Errorf("Similarity %d query %d. Neighbour %d is %d with %f, expected %d with %f", similarity, q, k, id, sims[k], expIDs[k], exp)
+				}
+			}
+		}
+	}
+}
+
+func TestKNNClassify(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	vectors, topics := genClusterTestVectors(rnd, 4, 100)
+
+	for _, vote := range []Vote{MajorityVote, WeightedVote} {
+		knn := NewKNN(5)
+		knn.Vote = vote
+		for i, sv := range vectors[:300] {
+			if err := knn.Add(sv, topics[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i, sv := range vectors[300:] {
+			class, prob := knn.Classify(sv)
+			if class != topics[300+i] || prob < 0.5 {
+				t.Errorf("Vote %d. Vector %d classified as %d with %f, expected %d", vote, i, class, prob, topics[300+i])
+			}
+		}
+	}
+}
+
+func TestKNNGen(t *testing.T) {
+	examples := []*GenSparseVector{
+		NewGenSparseVector(StringIndex{"cat", "purr"}, []Value{1, 1}),
+		NewGenSparseVector(StringIndex{"cat", "meow"}, []Value{1, 1}),
+		NewGenSparseVector(StringIndex{"dog", "woof"}, []Value{1, 1}),
+	}
+	knn := NewKNN(2)
+	for i, gsv := range examples {
+		if err := knn.AddGen(gsv, i/2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// "lion" is not in any example, but still counts towards the magnitude
+	query := NewGenSparseVector(StringIndex{"purr", "lion"}, []Value{1, 1})
+	ids, sims := knn.NeighboursGen(query)
+	if !reflect.DeepEqual(ids, []int{0}) || math.Abs(float64(sims[0]-query.Cos(examples[0]))) > 1e-6 {
+		t.Errorf("Neighbours not as expected. Have %v %v", ids, sims)
+	}
+	if class, prob := knn.ClassifyGen(query); class != 0 || prob != 1 {
+		t.Errorf("Classified as %d with %f", class, prob)
+	}
+	if class, _ := knn.ClassifyGen(NewGenSparseVector(StringIndex{"woof"}, []Value{1})); class != 1 {
+		t.Errorf("Classified as %d", class)
+	}
+	if class, prob := knn.ClassifyGen(NewGenSparseVector(StringIndex{"moo"}, []Value{1})); class != -1 || prob != 0 {
+		t.Errorf("Classified as %d with %f", class, prob)
+	}
+}
+
+func TestKNNErrors(t *testing.T) {
+	knn := NewKNN(1)
+	if err := knn.Add(NewSparseVectorUint32([]uint32{1}, []Value{1}), -1); err == nil {
+		t.Errorf("expected error for negative label")
+	}
+	if err := knn.Add(NewSparseVectorUint32([]uint32{1}, []Value{1}), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := knn.AddGen(NewGenSparseVector(StringIndex{"a"}, []Value{1}), 0); err == nil {
+		t.Errorf("expected error mixing example types")
+	}
+
+	knn = NewKNN(1)
+	if err := knn.AddGen(NewGenSparseVector(StringIndex{"a"}, []Value{1}), -1); err == nil {
+		t.Errorf("expected error for negative label")
+	}
+	if err := knn.AddGen(NewGenSparseVector(StringIndex{"a"}, []Value{1}), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := knn.Add(NewSparseVectorUint32([]uint32{1}, []Value{1}), 0); err == nil {
+		t.Errorf("expected error mixing example types")
+	}
+	if knn.Len() != 1 {
+		t.Errorf("have %d examples", knn.Len())
+	}
+}
+
+func TestKNNZeroValue(t *testing.T) {
+	knn := KNN{K: 1}
+	if class, _ := knn.Classify(NewSparseVectorUint32([]uint32{1}, []Value{1})); class != -1 {
+		t.Errorf("empty KNN classified as %d", class)
+	}
+	if err := knn.Add(NewSparseVectorUint32([]uint32{1}, []Value{1}), 2); err != nil {
+		t.Fatal(err)
+	}
+	if class, prob := knn.Classify(NewSparseVectorUint32([]uint32{1}, []Value{3})); class != 2 || prob != 1 {
+		t.Errorf("Classified as %d with %f", class, prob)
+	}
+
+	var gen KNN
+	gen.K = 1
+	if err := gen.AddGen(NewGenSparseVector(StringIndex{"a"}, []Value{1}), 0); err != nil {
+		t.Fatal(err)
+	}
+	if class, _ := gen.ClassifyGen(NewGenSparseVector(StringIndex{"a"}, []Value{1})); class != 0 {
+		t.Errorf("Classified as %d", class)
+	}
+}
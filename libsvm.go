@@ -0,0 +1,194 @@
+package sparsevector
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LibSVMRecord is a single line of a LIBSVM / SVMlight format file. A line
+// looks like
+//
+//	<label> [qid:<qid>] <index>:<value> <index>:<value> ... [# comment]
+//
+// Indices are used exactly as they appear in the file. Note that LIBSVM files
+// conventionally number features from 1.
+type LibSVMRecord struct {
+	Label float64
+	// QID is the query id. It is only meaningful if HasQID is set
+	QID     uint32
+	HasQID  bool
+	Vector  *SparseVectorUint32
+	Comment string
+}
+
+// LibSVMError reports a problem reading a LIBSVM file, and where it occurred.
+type LibSVMError struct {
+	Line int
+	Err  error
+}
+
+func (e *LibSVMError) Error() string {
+	return fmt.Sprintf("sparsevector: libsvm line %d: %v", e.Line, e.Err)
+}
+
+func (e *LibSVMError) Unwrap() error { return e.Err }
+
+// LibSVMReader reads records from a LIBSVM / SVMlight format file one line
+// at a time. Blank lines and lines containing only a comment are skipped.
+type LibSVMReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// NewLibSVMReader creates a LibSVMReader reading from r
+func NewLibSVMReader(r io.Reader) *LibSVMReader {
+	s := bufio.NewScanner(r)
+	// Lines in these files can be very long
+	s.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	return &LibSVMReader{s: s}
+}
+
+// Read returns the next record. At the end of the input it returns io.EOF.
+// Errors in the file are returned as *LibSVMError.
+func (r *LibSVMReader) Read() (LibSVMRecord, error) {
+	for r.s.Scan() {
+		r.line++
+		rec, ok, err := parseLibSVMLine(r.s.Bytes())
+		if err != nil {
+			return LibSVMRecord{}, &LibSVMError{Line: r.line, Err: err}
+		}
+		if ok {
+			return rec, nil
+		}
+	}
+	if err := r.s.Err(); err != nil {
+		return LibSVMRecord{}, &LibSVMError{Line: r.line + 1, Err: err}
+	}
+	return LibSVMRecord{}, io.EOF
+}
+
+// ReadAll reads all the remaining records
+func (r *LibSVMReader) ReadAll() ([]LibSVMRecord, error) {
+	var recs []LibSVMRecord
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return recs, nil
+		}
+		if err != nil {
+			return recs, err
+		}
+		recs = append(recs, rec)
+	}
+}
+
+// parseLibSVMLine parses a single line. ok is false if the line holds no
+// record
+func parseLibSVMLine(line []byte) (rec LibSVMRecord, ok bool, err error) {
+	if i := bytes.IndexByte(line, '#'); i >= 0 {
+		rec.Comment = string(bytes.TrimSpace(line[i+1:]))
+		line = line[:i]
+	}
+	fields := bytes.Fields(line)
+	if len(fields) == 0 {
+		return rec, false, nil
+	}
+
+	rec.Label, err = strconv.ParseFloat(string(fields[0]), 64)
+	if err != nil {
+		return rec, false, fmt.Errorf("bad label %q", fields[0])
+	}
+	fields = fields[1:]
+
+	if len(fields) > 0 && bytes.HasPrefix(fields[0], []byte("qid:")) {
+		qid, err := strconv.ParseUint(string(fields[0][4:]), 10, 32)
+		if err != nil {
+			return rec, false, fmt.Errorf("bad qid %q", fields[0])
+		}
+		rec.QID = uint32(qid)
+		rec.HasQID = true
+		fields = fields[1:]
+	}
+
+	indices := make([]uint32, len(fields))
+	values := make([]Value, len(fields))
+	for i, field := range fields {
+		colon := bytes.IndexByte(field, ':')
+		if colon < 0 {
+			return rec, false, fmt.Errorf("feature %q is not of the form index:value", field)
+		}
+		index, err := strconv.ParseUint(string(field[:colon]), 10, 32)
+		if err != nil {
+			return rec, false, fmt.Errorf("bad feature index %q", field[:colon])
+		}
+		value, err := strconv.ParseFloat(string(field[colon+1:]), 32)
+		if err != nil {
+			return rec, false, fmt.Errorf("bad feature value %q", field[colon+1:])
+		}
+		indices[i] = uint32(index)
+		values[i] = Value(value)
+	}
+
+	// Sort without NewSparseVectorUint32, as we check for repeats ourselves
+	rec.Vector = &SparseVectorUint32{indices: indices, values: values}
+	sort.Sort(sparseVectorUint32Sort{rec.Vector})
+	for i := 1; i < len(indices); i++ {
+		if rec.Vector.indices[i] == rec.Vector.indices[i-1] {
+			return rec, false, fmt.Errorf("feature index %d repeated", rec.Vector.indices[i])
+		}
+	}
+	return rec, true, nil
+}
+
+// LibSVMWriter writes records in LIBSVM / SVMlight format. Output is
+// buffered, so call Flush when you are done.
+type LibSVMWriter struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+// NewLibSVMWriter creates a LibSVMWriter writing to w
+func NewLibSVMWriter(w io.Writer) *LibSVMWriter {
+	return &LibSVMWriter{w: bufio.NewWriter(w)}
+}
+
+// Write writes a single record as a line. Comments must not contain newlines.
+func (w *LibSVMWriter) Write(rec LibSVMRecord) error {
+	if strings.ContainsAny(rec.Comment, "\r\n") {
+		return errors.New("sparsevector: libsvm comment contains a newline")
+	}
+
+	buf := strconv.AppendFloat(w.buf[:0], rec.Label, 'g', -1, 64)
+	if rec.HasQID {
+		buf = append(buf, " qid:"...)
+		buf = strconv.AppendUint(buf, uint64(rec.QID), 10)
+	}
+	if rec.Vector != nil {
+		for i, index := range rec.Vector.indices {
+			buf = append(buf, ' ')
+			buf = strconv.AppendUint(buf, uint64(index), 10)
+			buf = append(buf, ':')
+			buf = strconv.AppendFloat(buf, float64(rec.Vector.values[i]), 'g', -1, 32)
+		}
+	}
+	if rec.Comment != "" {
+		buf = append(buf, " # "...)
+		buf = append(buf, rec.Comment...)
+	}
+	buf = append(buf, '\n')
+	w.buf = buf
+
+	_, err := w.w.Write(buf)
+	return err
+}
+
+// Flush writes any buffered data to the underlying io.Writer
+func (w *LibSVMWriter) Flush() error {
+	return w.w.Flush()
+}
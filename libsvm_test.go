@@ -0,0 +1,123 @@
+package sparsevector
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const libSVMTestData = `# A test file
++1 1:0.5 3:2 10:-1
+
+-1 qid:3 2:1 1:4 # out of order
+0.25   7:1e-3	# trailing comment
+2
+`
+
+func TestLibSVMReader(t *testing.T) {
+	r := NewLibSVMReader(strings.NewReader(libSVMTestData))
+	recs, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []LibSVMRecord{{
+		Label:  1,
+		Vector: NewSparseVectorUint32([]uint32{1, 3, 10}, []Value{0.5, 2, -1}),
+	}, {
+		Label:   -1,
+		QID:     3,
+		HasQID:  true,
+		Vector:  NewSparseVectorUint32([]uint32{1, 2}, []Value{4, 1}),
+		Comment: "out of order",
+	}, {
+		Label:   0.25,
+		Vector:  NewSparseVectorUint32([]uint32{7}, []Value{1e-3}),
+		Comment: "trailing comment",
+	}, {
+		Label:  2,
+		Vector: NewSparseVectorUint32([]uint32{}, []Value{}),
+	},
+	}
+
+	if !reflect.DeepEqual(exp, recs) {
+		t.Errorf("records not as expected. Have %#v", recs)
+	}
+
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("expected EOF, have %v", err)
+	}
+}
+
+func TestLibSVMReaderErrors(t *testing.T) {
+	tests := []struct {
+		data string
+		line int
+	}{
+		{data: "x 1:2", line: 1},
+		{data: "1 1:2\n\n1 qid:x 1:2", line: 3},
+		{data: "1 1:2\n1 1", line: 2},
+		{data: "1 a:2", line: 1},
+		{data: "1 4294967296:2", line: 1},
+		{data: "1 1:z", line: 1},
+		{data: "# comment\n1 1:1 1:2", line: 2},
+	}
+
+	for i, test := range tests {
+		r := NewLibSVMReader(strings.NewReader(test.data))
+		_, err := r.ReadAll()
+		var lerr *LibSVMError
+		if !errors.As(err, &lerr) {
+			t.Errorf("Test %d. Expected a LibSVMError, have %v", i, err)
+			continue
+		}
+		if lerr.Line != test.line {
+			t.Errorf("Test %d. Error on line %d, expected %d. %v", i, lerr.Line, test.line, err)
+		}
+	}
+}
+
+func TestLibSVMWriter(t *testing.T) {
+	recs := []LibSVMRecord{{
+		Label:  1,
+		Vector: NewSparseVectorUint32([]uint32{3, 1, 10}, []Value{2, 0.5, -1}),
+	}, {
+		Label:   -1.5,
+		QID:     3,
+		HasQID:  true,
+		Vector:  NewSparseVectorUint32([]uint32{}, []Value{}),
+		Comment: "hello",
+	},
+	}
+
+	var buf bytes.Buffer
+	w := NewLibSVMWriter(&buf)
+	for _, rec := range recs {
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "1 1:0.5 3:2 10:-1\n-1.5 qid:3 # hello\n"
+	if buf.String() != exp {
+		t.Errorf("output not as expected. Have %q", buf.String())
+	}
+
+	read, err := NewLibSVMReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(recs, read) {
+		t.Errorf("round trip not as expected. Have %#v", read)
+	}
+
+	if err := w.Write(LibSVMRecord{Comment: "a\nb"}); err == nil {
+		t.Errorf("expected an error for a comment containing a newline")
+	}
+}
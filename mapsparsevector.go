@@ -2,6 +2,7 @@ package sparsevector
 
 import (
 	"math"
+	"sort"
 )
 
 // MapSparseVector is a sparse vector implemented using a map
@@ -21,9 +22,11 @@ func NewMapSparseVector(indices []uint32, values []Value) *MapSparseVector {
 		m[index] = values[i]
 	}
 
-	return &MapSparseVector{
+	mv := &MapSparseVector{
 		values: m,
 	}
+	debugCheck(mv)
+	return mv
 }
 
 // Mag returns the magnitude of the vector
@@ -107,4 +110,19 @@ func (m *MapSparseVector) Mult(l Value) {
 	for k, v := range m.values {
 		m.values[k] = l * v
 	}
+	debugCheck(m)
+}
+
+// sorted returns the contents of the map as parallel arrays ordered by index
+func (m *MapSparseVector) sorted() ([]uint32, []Value) {
+	indices := make([]uint32, 0, len(m.values))
+	for k := range m.values {
+		indices = append(indices, k)
+	}
+	sort.Sort(Uint32Index(indices))
+	values := make([]Value, len(indices))
+	for i, k := range indices {
+		values[i] = m.values[k]
+	}
+	return indices, values
 }
@@ -0,0 +1,358 @@
+package sparsevector
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// MatrixMarketField is the type of the values in a Matrix Market file
+type MatrixMarketField string
+
+// MatrixMarketSymmetry describes which entries of a matrix are stored in a
+// Matrix Market file
+type MatrixMarketSymmetry string
+
+const (
+	MatrixMarketReal    MatrixMarketField = "real"
+	MatrixMarketInteger MatrixMarketField = "integer"
+	// Pattern files hold no values. Every entry present has the value 1.
+	MatrixMarketPattern MatrixMarketField = "pattern"
+
+	MatrixMarketGeneral MatrixMarketSymmetry = "general"
+	// Symmetric files store only the lower triangle of the matrix.
+	MatrixMarketSymmetric MatrixMarketSymmetry = "symmetric"
+)
+
+// MatrixMarket is the contents of a Matrix Market (.mtx) coordinate format
+// file. The matrix is held as one SparseVectorUint32 per row, indexed by
+// column. Rows and columns are numbered from zero, whereas in the file they
+// are numbered from one.
+type MatrixMarket struct {
+	NumRows  int
+	NumCols  int
+	Field    MatrixMarketField
+	Symmetry MatrixMarketSymmetry
+	// Rows has an entry for each row. When reading a file there are NumRows
+	// of them, and rows with no entries are empty vectors. When writing, nil
+	// rows are empty and Rows may be shorter than NumRows. When reading a
+	// symmetric file both triangles of the matrix are filled in.
+	Rows []*SparseVectorUint32
+}
+
+// MaxMatrixMarketRows is the largest number of rows ReadMatrixMarket accepts.
+// Reading allocates a vector for every row, about 64 bytes each, so this
+// stops a tiny file with a huge size line from using a huge amount of
+// memory. Raise it to read larger matrices.
+var MaxMatrixMarketRows = 1 << 24
+
+// ReadMatrixMarket reads a Matrix Market coordinate format file. real,
+// integer and pattern fields are supported, with general or symmetric
+// layouts.
+func ReadMatrixMarket(r io.Reader) (*MatrixMarket, error) {
+	s := bufio.NewScanner(r)
+	var line int
+	lineErr := func(format string, a ...interface{}) error {
+		return fmt.Errorf("sparsevector: matrix market line %d: %s", line, fmt.Sprintf(format, a...))
+	}
+
+	// Header
+	if !s.Scan() {
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("sparsevector: matrix market file is empty")
+	}
+	line++
+	m, err := parseMatrixMarketBanner(s.Bytes())
+	if err != nil {
+		return nil, lineErr("%v", err)
+	}
+
+	// Skip comments to find the size line
+	var fields [][]byte
+	for s.Scan() {
+		line++
+		b := bytes.TrimSpace(s.Bytes())
+		if len(b) == 0 || b[0] == '%' {
+			continue
+		}
+		fields = bytes.Fields(b)
+		break
+	}
+	if fields == nil {
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, lineErr("missing size line")
+	}
+	if len(fields) != 3 {
+		return nil, lineErr("size line should have 3 fields, has %d", len(fields))
+	}
+	var sizes [3]int
+	for i, f := range fields {
+		v, err := strconv.ParseUint(string(f), 10, 32)
+		if err != nil {
+			return nil, lineErr("bad size %q", f)
+		}
+		sizes[i] = int(v)
+	}
+	m.NumRows, m.NumCols = sizes[0], sizes[1]
+	nnz := sizes[2]
+	if m.NumRows > MaxMatrixMarketRows {
+		return nil, lineErr("%d rows is more than MaxMatrixMarketRows", m.NumRows)
+	}
+	if m.Symmetry == MatrixMarketSymmetric && m.NumRows != m.NumCols {
+		return nil, lineErr("symmetric matrix is not square")
+	}
+
+	// The number of entries on the size line isn't trusted, so entries are
+	// collected as they are read rather than allocating space up front
+	var entries []matrixMarketEntry
+	add := func(row, col int, value Value) {
+		entries = append(entries, matrixMarketEntry{row: uint32(row), col: uint32(col), value: value})
+	}
+
+	expFields := 3
+	if m.Field == MatrixMarketPattern {
+		expFields = 2
+	}
+	var count int
+	for s.Scan() {
+		line++
+		b := bytes.TrimSpace(s.Bytes())
+		if len(b) == 0 || b[0] == '%' {
+			continue
+		}
+		if count == nnz {
+			return nil, lineErr("more than the %d entries given in the size line", nnz)
+		}
+		fields = bytes.Fields(b)
+		if len(fields) != expFields {
+			return nil, lineErr("entry should have %d fields, has %d", expFields, len(fields))
+		}
+		row, err := strconv.Atoi(string(fields[0]))
+		if err != nil || row < 1 || row > m.NumRows {
+			return nil, lineErr("bad row %q", fields[0])
+		}
+		col, err := strconv.Atoi(string(fields[1]))
+		if err != nil || col < 1 || col > m.NumCols {
+			return nil, lineErr("bad column %q", fields[1])
+		}
+		row--
+		col--
+
+		value := Value(1)
+		switch m.Field {
+		case MatrixMarketReal:
+			v, err := strconv.ParseFloat(string(fields[2]), 32)
+			if err != nil {
+				return nil, lineErr("bad value %q", fields[2])
+			}
+			value = Value(v)
+		case MatrixMarketInteger:
+			v, err := strconv.ParseInt(string(fields[2]), 10, 64)
+			if err != nil {
+				return nil, lineErr("bad integer value %q", fields[2])
+			}
+			value = Value(v)
+		}
+
+		if m.Symmetry == MatrixMarketSymmetric {
+			if col > row {
+				return nil, lineErr("entry (%d, %d) is above the diagonal of a symmetric matrix", row+1, col+1)
+			}
+			if col != row {
+				add(col, row, value)
+			}
+		}
+		add(row, col, value)
+		count++
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if count != nnz {
+		return nil, fmt.Errorf("sparsevector: matrix market file has %d entries, expected %d", count, nnz)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].row != entries[j].row {
+			return entries[i].row < entries[j].row
+		}
+		return entries[i].col < entries[j].col
+	})
+	for i := 1; i < len(entries); i++ {
+		if e := entries[i]; e.row == entries[i-1].row && e.col == entries[i-1].col {
+			return nil, fmt.Errorf("sparsevector: matrix market entry (%d, %d) repeated", e.row+1, e.col+1)
+		}
+	}
+
+	// Rows share one backing array, and the vectors are allocated together.
+	// Each row's capacity is limited to its length so appending to one can't
+	// overwrite the next.
+	indices := make([]uint32, len(entries))
+	values := make([]Value, len(entries))
+	for i, e := range entries {
+		indices[i] = e.col
+		values[i] = e.value
+	}
+	vectors := make([]SparseVectorUint32, m.NumRows)
+	m.Rows = make([]*SparseVectorUint32, m.NumRows)
+	start := 0
+	for row := range m.Rows {
+		end := start
+		for end < len(entries) && entries[end].row == uint32(row) {
+			end++
+		}
+		vectors[row].indices = indices[start:end:end]
+		vectors[row].values = values[start:end:end]
+		m.Rows[row] = &vectors[row]
+		start = end
+	}
+	return m, nil
+}
+
+// matrixMarketEntry is an entry read from a Matrix Market file
+type matrixMarketEntry struct {
+	row, col uint32
+	value    Value
+}
+
+func parseMatrixMarketBanner(b []byte) (*MatrixMarket, error) {
+	fields := bytes.Fields(bytes.ToLower(b))
+	if len(fields) != 5 || string(fields[0]) != "%%matrixmarket" || string(fields[1]) != "matrix" {
+		return nil, fmt.Errorf("bad header %q", b)
+	}
+	if string(fields[2]) != "coordinate" {
+		return nil, fmt.Errorf("unsupported format %q", fields[2])
+	}
+	m := &MatrixMarket{
+		Field:    MatrixMarketField(fields[3]),
+		Symmetry: MatrixMarketSymmetry(fields[4]),
+	}
+	switch m.Field {
+	case MatrixMarketReal, MatrixMarketInteger, MatrixMarketPattern:
+	default:
+		return nil, fmt.Errorf("unsupported field %q", m.Field)
+	}
+	switch m.Symmetry {
+	case MatrixMarketGeneral, MatrixMarketSymmetric:
+	default:
+		return nil, fmt.Errorf("unsupported symmetry %q", m.Symmetry)
+	}
+	return m, nil
+}
+
+// WriteMatrixMarket writes m in Matrix Market coordinate format. An empty
+// Field is written as real, and an empty Symmetry as general.
+//
+// If Symmetry is MatrixMarketSymmetric only the lower triangle is written,
+// and the matrix must actually be symmetric. If Field is MatrixMarketInteger
+// all values must be integers. If Field is MatrixMarketPattern values are not
+// written.
+func WriteMatrixMarket(w io.Writer, m *MatrixMarket) error {
+	field := m.Field
+	if field == "" {
+		field = MatrixMarketReal
+	}
+	symmetry := m.Symmetry
+	if symmetry == "" {
+		symmetry = MatrixMarketGeneral
+	}
+	switch field {
+	case MatrixMarketReal, MatrixMarketInteger, MatrixMarketPattern:
+	default:
+		return fmt.Errorf("sparsevector: unsupported matrix market field %q", field)
+	}
+	switch symmetry {
+	case MatrixMarketGeneral, MatrixMarketSymmetric:
+	default:
+		return fmt.Errorf("sparsevector: unsupported matrix market symmetry %q", symmetry)
+	}
+	if len(m.Rows) > m.NumRows {
+		return fmt.Errorf("sparsevector: matrix has %d rows but NumRows is %d", len(m.Rows), m.NumRows)
+	}
+	if symmetry == MatrixMarketSymmetric && m.NumRows != m.NumCols {
+		return errors.New("sparsevector: symmetric matrix is not square")
+	}
+
+	// Check the matrix and count the entries we'll write before we write
+	// anything
+	var nnz int
+	for row, v := range m.Rows {
+		if v == nil {
+			continue
+		}
+		for i, col := range v.indices {
+			if int64(col) >= int64(m.NumCols) {
+				return fmt.Errorf("sparsevector: column %d in row %d is outside the matrix", col, row)
+			}
+			if symmetry == MatrixMarketSymmetric {
+				other, ok := m.at(int(col), uint32(row))
+				if !ok || (field != MatrixMarketPattern && other != v.values[i]) {
+					return fmt.Errorf("sparsevector: matrix is not symmetric at (%d, %d)", row, col)
+				}
+				if int(col) > row {
+					continue
+				}
+			}
+			if field == MatrixMarketInteger {
+				if f := float64(v.values[i]); f != math.Trunc(f) {
+					return fmt.Errorf("sparsevector: value %v at (%d, %d) is not an integer", f, row, col)
+				}
+			}
+			nnz++
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%%%%MatrixMarket matrix coordinate %s %s\n", field, symmetry)
+	fmt.Fprintf(bw, "%d %d %d\n", m.NumRows, m.NumCols, nnz)
+
+	var buf []byte
+	for row, v := range m.Rows {
+		if v == nil {
+			continue
+		}
+		for i, col := range v.indices {
+			if symmetry == MatrixMarketSymmetric && int(col) > row {
+				break
+			}
+			buf = strconv.AppendInt(buf[:0], int64(row+1), 10)
+			buf = append(buf, ' ')
+			buf = strconv.AppendUint(buf, uint64(col)+1, 10)
+			switch field {
+			case MatrixMarketReal:
+				buf = append(buf, ' ')
+				buf = strconv.AppendFloat(buf, float64(v.values[i]), 'g', -1, 32)
+			case MatrixMarketInteger:
+				buf = append(buf, ' ')
+				buf = strconv.AppendInt(buf, int64(v.values[i]), 10)
+			}
+			buf = append(buf, '\n')
+			if _, err := bw.Write(buf); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// at returns the value in the matrix at row, col
+func (m *MatrixMarket) at(row int, col uint32) (Value, bool) {
+	if row >= len(m.Rows) || m.Rows[row] == nil {
+		return 0, false
+	}
+	v := m.Rows[row]
+	i := sort.Search(len(v.indices), func(i int) bool { return v.indices[i] >= col })
+	if i == len(v.indices) || v.indices[i] != col {
+		return 0, false
+	}
+	return v.values[i], true
+}
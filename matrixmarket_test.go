@@ -0,0 +1,251 @@
+package sparsevector
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadMatrixMarket(t *testing.T) {
+	tests := []struct {
+		data string
+		exp  *MatrixMarket
+	}{{
+		data: `%%MatrixMarket matrix coordinate real general
+% a comment
+3 4 4
+1 1 1.5
+3 4 -2
+1 3 2
+
+3 1 1e3
+`,
+		exp: &MatrixMarket{
+			NumRows:  3,
+			NumCols:  4,
+			Field:    MatrixMarketReal,
+			Symmetry: MatrixMarketGeneral,
+			Rows: []*SparseVectorUint32{
+				NewSparseVectorUint32([]uint32{0, 2}, []Value{1.5, 2}),
+				NewSparseVectorUint32([]uint32{}, []Value{}),
+				NewSparseVectorUint32([]uint32{0, 3}, []Value{1000, -2}),
+			},
+		},
+	}, {
+		data: `%%MatrixMarket matrix coordinate integer symmetric
+3 3 3
+1 1 7
+3 1 2
+3 2 -4
+`,
+		exp: &MatrixMarket{
+			NumRows:  3,
+			NumCols:  3,
+			Field:    MatrixMarketInteger,
+			Symmetry: MatrixMarketSymmetric,
+			Rows: []*SparseVectorUint32{
+				NewSparseVectorUint32([]uint32{0, 2}, []Value{7, 2}),
+				NewSparseVectorUint32([]uint32{2}, []Value{-4}),
+				NewSparseVectorUint32([]uint32{0, 1}, []Value{2, -4}),
+			},
+		},
+	}, {
+		data: `%%MatrixMarket matrix coordinate pattern general
+2 2 2
+2 1
+1 2
+`,
+		exp: &MatrixMarket{
+			NumRows:  2,
+			NumCols:  2,
+			Field:    MatrixMarketPattern,
+			Symmetry: MatrixMarketGeneral,
+			Rows: []*SparseVectorUint32{
+				NewSparseVectorUint32([]uint32{1}, []Value{1}),
+				NewSparseVectorUint32([]uint32{0}, []Value{1}),
+			},
+		},
+	}, {
+		// Empty rows after the last entry are filled in
+		data: `%%MatrixMarket matrix coordinate real general
+3 2 1
+1 2 3
+`,
+		exp: &MatrixMarket{
+			NumRows:  3,
+			NumCols:  2,
+			Field:    MatrixMarketReal,
+			Symmetry: MatrixMarketGeneral,
+			Rows: []*SparseVectorUint32{
+				NewSparseVectorUint32([]uint32{1}, []Value{3}),
+				NewSparseVectorUint32([]uint32{}, []Value{}),
+				NewSparseVectorUint32([]uint32{}, []Value{}),
+			},
+		},
+	}, {
+		data: `%%MatrixMarket matrix coordinate real general
+0 0 0
+`,
+		exp: &MatrixMarket{
+			Field:    MatrixMarketReal,
+			Symmetry: MatrixMarketGeneral,
+			Rows:     []*SparseVectorUint32{},
+		},
+	},
+	}
+
+	for i, test := range tests {
+		m, err := ReadMatrixMarket(strings.NewReader(test.data))
+		if err != nil {
+			t.Fatalf("Test %d. %v", i, err)
+		}
+		if !reflect.DeepEqual(test.exp, m) {
+			t.Errorf("Test %d. Matrix not as expected. Have %#v", i, m)
+		}
+	}
+}
+
+func TestReadMatrixMarketErrors(t *testing.T) {
+	tests := []string{
+		``,
+		`%%MatrixMarket matrix array real general`,
+		`%%MatrixMarket matrix coordinate complex general`,
+		`%%MatrixMarket matrix coordinate real hermitian`,
+		"%%MatrixMarket matrix coordinate real general\n",
+		"%%MatrixMarket matrix coordinate real general\n2 2\n",
+		"%%MatrixMarket matrix coordinate real general\n2 2 1\n3 1 1\n",
+		"%%MatrixMarket matrix coordinate real general\n2 2 1\n1 0 1\n",
+		"%%MatrixMarket matrix coordinate real general\n2 2 1\n1 1 x\n",
+		"%%MatrixMarket matrix coordinate real general\n2 2 1\n1 1\n",
+		"%%MatrixMarket matrix coordinate real general\n2 2 2\n1 1 1\n",
+		"%%MatrixMarket matrix coordinate real general\n2 2 1\n1 1 1\n2 2 1\n",
+		"%%MatrixMarket matrix coordinate real general\n2 2 2\n1 1 1\n1 1 2\n",
+		"%%MatrixMarket matrix coordinate integer general\n2 2 1\n1 1 1.5\n",
+		"%%MatrixMarket matrix coordinate real symmetric\n2 3 1\n1 1 1\n",
+		"%%MatrixMarket matrix coordinate real symmetric\n2 2 1\n1 2 1\n",
+		// A huge size line doesn't cause a huge allocation
+		"%%MatrixMarket matrix coordinate real general\n400000000 400000000 0\n",
+	}
+
+	for i, test := range tests {
+		if _, err := ReadMatrixMarket(strings.NewReader(test)); err == nil {
+			t.Errorf("Test %d. Expected an error", i)
+		}
+	}
+}
+
+func TestWriteMatrixMarket(t *testing.T) {
+	tests := []struct {
+		m   *MatrixMarket
+		exp string
+	}{{
+		m: &MatrixMarket{
+			NumRows: 3,
+			NumCols: 4,
+			Rows: []*SparseVectorUint32{
+				NewSparseVectorUint32([]uint32{0, 2}, []Value{1.5, 2}),
+				nil,
+				NewSparseVectorUint32([]uint32{0, 3}, []Value{1000, -2}),
+			},
+		},
+		exp: `%%MatrixMarket matrix coordinate real general
+3 4 4
+1 1 1.5
+1 3 2
+3 1 1000
+3 4 -2
+`,
+	}, {
+		m: &MatrixMarket{
+			NumRows:  3,
+			NumCols:  3,
+			Field:    MatrixMarketInteger,
+			Symmetry: MatrixMarketSymmetric,
+			Rows: []*SparseVectorUint32{
+				NewSparseVectorUint32([]uint32{0, 2}, []Value{7, 2}),
+				NewSparseVectorUint32([]uint32{2}, []Value{-4}),
+				NewSparseVectorUint32([]uint32{0, 1}, []Value{2, -4}),
+			},
+		},
+		exp: `%%MatrixMarket matrix coordinate integer symmetric
+3 3 3
+1 1 7
+3 1 2
+3 2 -4
+`,
+	}, {
+		m: &MatrixMarket{
+			NumRows: 2,
+			NumCols: 2,
+			Field:   MatrixMarketPattern,
+			Rows: []*SparseVectorUint32{
+				NewSparseVectorUint32([]uint32{1}, []Value{1}),
+				NewSparseVectorUint32([]uint32{0}, []Value{1}),
+			},
+		},
+		exp: `%%MatrixMarket matrix coordinate pattern general
+2 2 2
+1 2
+2 1
+`,
+	},
+	}
+
+	for i, test := range tests {
+		var buf bytes.Buffer
+		if err := WriteMatrixMarket(&buf, test.m); err != nil {
+			t.Fatalf("Test %d. %v", i, err)
+		}
+		if buf.String() != test.exp {
+			t.Errorf("Test %d. Output not as expected. Have %s", i, buf.String())
+		}
+	}
+}
+
+func TestWriteMatrixMarketErrors(t *testing.T) {
+	tests := []*MatrixMarket{{
+		NumRows: 1,
+		NumCols: 1,
+		Rows:    []*SparseVectorUint32{NewSparseVectorUint32([]uint32{1}, []Value{1})},
+	}, {
+		NumRows: 1,
+		NumCols: 2,
+		Rows: []*SparseVectorUint32{
+			NewSparseVectorUint32([]uint32{1}, []Value{1}),
+			NewSparseVectorUint32([]uint32{1}, []Value{1}),
+		},
+	}, {
+		NumRows: 1,
+		NumCols: 1,
+		Field:   MatrixMarketInteger,
+		Rows:    []*SparseVectorUint32{NewSparseVectorUint32([]uint32{0}, []Value{1.5})},
+	}, {
+		NumRows:  2,
+		NumCols:  2,
+		Symmetry: MatrixMarketSymmetric,
+		Rows: []*SparseVectorUint32{
+			NewSparseVectorUint32([]uint32{1}, []Value{1}),
+			NewSparseVectorUint32([]uint32{0}, []Value{2}),
+		},
+	}, {
+		NumRows:  2,
+		NumCols:  2,
+		Symmetry: MatrixMarketSymmetric,
+		Rows: []*SparseVectorUint32{
+			NewSparseVectorUint32([]uint32{1}, []Value{1}),
+		},
+	}, {
+		NumRows: 1,
+		NumCols: 1,
+		Field:   "complex",
+	},
+	}
+
+	for i, test := range tests {
+		var buf bytes.Buffer
+		if err := WriteMatrixMarket(&buf, test); err == nil {
+			t.Errorf("Test %d. Expected an error", i)
+		}
+	}
+}
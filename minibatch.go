@@ -0,0 +1,211 @@
+package sparsevector
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// MiniBatchKMeans clusters a stream of vectors by cosine similarity, a batch
+// at a time, so the whole collection never needs to be in memory.
+//
+// Each centroid is the running mean of the normalized vectors assigned to it.
+// When a batch adds m vectors to a centroid that has already seen n, the
+// centroid moves towards their mean with a learning rate of m / (n + m), so
+// each centroid learns more slowly as it sees more vectors.
+//
+// The state can be saved with MarshalBinary at any point, and clustering
+// resumed later after UnmarshalBinary.
+type MiniBatchKMeans struct {
+	// K is the number of clusters. It can't be changed after the first
+	// Update.
+	K int
+	// MaxCentroidSize is the maximum number of entries kept in each centroid.
+	// If it is zero centroids are not pruned, which is the default.
+	MaxCentroidSize int
+	// Workers is the number of goroutines used to assign vectors to
+	// clusters. If it is zero GOMAXPROCS goroutines are used.
+	Workers int
+	// Seed seeds the k-means++ choice of initial centroids from the first
+	// batch. NewMiniBatchKMeans sets it to 1.
+	Seed int64
+
+	// means are the running means, and centroids the means normalized
+	means     []*SparseVectorUint32
+	centroids []*SparseVectorUint32
+	counts    []int
+	acc       *Accumulator
+}
+
+// NewMiniBatchKMeans creates a MiniBatchKMeans for k clusters
+func NewMiniBatchKMeans(k int) *MiniBatchKMeans {
+	return &MiniBatchKMeans{
+		K:    k,
+		Seed: 1,
+	}
+}
+
+// Update assigns a batch of vectors to clusters and moves the centroids
+// towards them. It returns the cluster of each vector in the batch. The first
+// batch chooses the initial centroids, so must have at least K vectors.
+func (mb *MiniBatchKMeans) Update(batch []*SparseVectorUint32) ([]int, error) {
+	if mb.centroids == nil {
+		if mb.K < 1 {
+			return nil, errors.New("sparsevector: MiniBatchKMeans needs at least one cluster")
+		}
+		if len(batch) < mb.K {
+			return nil, fmt.Errorf("sparsevector: first MiniBatchKMeans batch has %d vectors, needs at least %d", len(batch), mb.K)
+		}
+		km := KMeans{K: mb.K}
+		mb.centroids = km.seed(batch, rand.New(rand.NewSource(mb.Seed)))
+		mb.means = make([]*SparseVectorUint32, mb.K)
+		for c, centroid := range mb.centroids {
+			// The seed isn't counted, so it is replaced by the mean of the
+			// first vectors assigned to it
+			mb.means[c] = centroid
+		}
+		mb.counts = make([]int, mb.K)
+	}
+	if mb.K != len(mb.centroids) {
+		return nil, fmt.Errorf("sparsevector: MiniBatchKMeans K is %d, but it has %d centroids", mb.K, len(mb.centroids))
+	}
+	if mb.acc == nil {
+		mb.acc = NewAccumulator(0)
+	}
+
+	for _, sv := range batch {
+		sv.Mag()
+	}
+	labels := make([]int, len(batch))
+	for i := range labels {
+		labels[i] = -1
+	}
+	cos := make([]Value, len(batch))
+	assignClusters(mb.centroids, batch, labels, cos, mb.Workers)
+
+	members := make([][]int, mb.K)
+	for i, label := range labels {
+		members[label] = append(members[label], i)
+	}
+	for c, m := range members {
+		if len(m) == 0 {
+			continue
+		}
+		n := mb.counts[c]
+		total := Value(n + len(m))
+		if n > 0 {
+			mb.acc.AddScaled(mb.means[c], Value(n)/total)
+		}
+		for _, i := range m {
+			if mag := batch[i].Mag(); mag != 0 {
+				mb.acc.AddScaled(batch[i], 1/(mag*total))
+			}
+		}
+		mb.means[c] = prune(mb.acc.Sum(), mb.MaxCentroidSize)
+		mb.counts[c] += len(m)
+		mb.centroids[c] = normalizedCopy(mb.means[c])
+	}
+	return labels, nil
+}
+
+// Centroids returns the centroids of the clusters. They have magnitude 1,
+// and must not be modified.
+func (mb *MiniBatchKMeans) Centroids() []*SparseVectorUint32 { return mb.centroids }
+
+// Counts returns the number of vectors assigned to each cluster so far
+func (mb *MiniBatchKMeans) Counts() []int { return mb.counts }
+
+// Predict returns the cluster a vector is closest to, and its cosine with
+// the centroid. It must not be called before the first Update.
+func (mb *MiniBatchKMeans) Predict(sv *SparseVectorUint32) (int, Value) {
+	return nearestCentroid(mb.centroids, sv)
+}
+
+// MarshalBinary saves the state of the clustering.
+//
+// The encoding is a version byte, then K, MaxCentroidSize and Seed as
+// varints, then for each cluster the number of vectors it has seen and its
+// running mean.
+func (mb *MiniBatchKMeans) MarshalBinary() ([]byte, error) {
+	buf := []byte{binaryVersion}
+	buf = binary.AppendUvarint(buf, uint64(mb.K))
+	buf = binary.AppendUvarint(buf, uint64(mb.MaxCentroidSize))
+	buf = binary.AppendVarint(buf, mb.Seed)
+	buf = binary.AppendUvarint(buf, uint64(len(mb.means)))
+	for c, mean := range mb.means {
+		buf = binary.AppendUvarint(buf, uint64(mb.counts[c]))
+		var err error
+		if buf, err = appendEmbeddedVector(buf, mean); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary restores a clustering saved with MarshalBinary. It
+// replaces the contents of mb, except for Workers.
+func (mb *MiniBatchKMeans) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return errors.New("sparsevector: encoded MiniBatchKMeans too short")
+	}
+	if data[0] != binaryVersion {
+		return fmt.Errorf("sparsevector: unsupported encoding version %d", data[0])
+	}
+	data = data[1:]
+
+	k, data, err := readUvarintInt(data)
+	if err != nil {
+		return err
+	}
+	maxSize, data, err := readUvarintInt(data)
+	if err != nil {
+		return err
+	}
+	seed, n := binary.Varint(data)
+	if n <= 0 {
+		return errors.New("sparsevector: bad MiniBatchKMeans seed")
+	}
+	l, data, err := readUvarintInt(data[n:])
+	if err != nil {
+		return err
+	}
+	if l != 0 && l != k {
+		return fmt.Errorf("sparsevector: MiniBatchKMeans has %d clusters, expected %d", l, k)
+	}
+
+	nmb := &MiniBatchKMeans{
+		K:               k,
+		MaxCentroidSize: maxSize,
+		Workers:         mb.Workers,
+		Seed:            seed,
+	}
+	for c := 0; c < l; c++ {
+		var count int
+		var mean *SparseVectorUint32
+		if count, data, err = readUvarintInt(data); err != nil {
+			return err
+		}
+		if mean, data, err = readEmbeddedVector(data); err != nil {
+			return err
+		}
+		nmb.counts = append(nmb.counts, count)
+		nmb.means = append(nmb.means, mean)
+		nmb.centroids = append(nmb.centroids, normalizedCopy(mean))
+	}
+	if len(data) != 0 {
+		return fmt.Errorf("sparsevector: %d unexpected bytes after MiniBatchKMeans", len(data))
+	}
+	*mb = *nmb
+	return nil
+}
+
+// readUvarintInt reads a uvarint that must fit in an int32
+func readUvarintInt(data []byte) (int, []byte, error) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 || v > math.MaxInt32 {
+		return 0, nil, errors.New("sparsevector: bad encoded integer")
+	}
+	return int(v), data[n:], nil
+}
@@ -0,0 +1,136 @@
+package sparsevector
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestMiniBatchKMeans(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	vectors, topics := genClusterTestVectors(rnd, 3, 100)
+
+	mb := NewMiniBatchKMeans(3)
+	mb.MaxCentroidSize = 50
+	for start := 0; start < len(vectors); start += 50 {
+		labels, err := mb.Update(vectors[start : start+50])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(labels) != 50 {
+			t.Fatalf("have %d labels", len(labels))
+		}
+	}
+
+	var total int
+	for c, count := range mb.Counts() {
+		total += count
+		centroid := mb.Centroids()[c]
+		if len(centroid.indices) > 50 {
+			t.Errorf("centroid %d has %d entries", c, len(centroid.indices))
+		}
+		if math.Abs(float64(centroid.Mag())-1) > 1e-6 {
+			t.Errorf("centroid %d has magnitude %f", c, centroid.Mag())
+		}
+	}
+	if total != len(vectors) {
+		t.Errorf("counts total %d, expected %d", total, len(vectors))
+	}
+
+	labels := make([]int, len(vectors))
+	for i, sv := range vectors {
+		labels[i], _ = mb.Predict(sv)
+	}
+	checkClustersMatch(t, labels, topics)
+
+	mb.K = 4
+	if _, err := mb.Update(vectors[:50]); err == nil {
+		t.Errorf("expected an error after changing K")
+	}
+}
+
+func TestMiniBatchKMeansMean(t *testing.T) {
+	// With one cluster the centroid is the mean of all the normalized
+	// vectors, however they are batched
+	vectors := []*SparseVectorUint32{
+		NewSparseVectorUint32([]uint32{1, 2}, []Value{3, 4}),
+		NewSparseVectorUint32([]uint32{2}, []Value{2}),
+		NewSparseVectorUint32([]uint32{3}, []Value{5}),
+	}
+	mb := NewMiniBatchKMeans(1)
+	if _, err := mb.Update(vectors[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mb.Update(vectors[1:]); err != nil {
+		t.Fatal(err)
+	}
+	exp := []Value{0.6 / 3, 1.8 / 3, 1.0 / 3}
+	for i, v := range mb.means[0].values {
+		if math.Abs(float64(v-exp[i])) > 1e-6 {
+			t.Errorf("mean not as expected. Have %v", mb.means[0].values)
+			break
+		}
+	}
+}
+
+func TestMiniBatchKMeansCheckpoint(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	vectors, _ := genClusterTestVectors(rnd, 3, 60)
+	batches := [][]*SparseVectorUint32{vectors[:60], vectors[60:120], vectors[120:]}
+
+	// Run straight through
+	mb := NewMiniBatchKMeans(3)
+	for _, batch := range batches {
+		if _, err := mb.Update(batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Checkpoint after the first batch and resume
+	mb2 := NewMiniBatchKMeans(3)
+	if _, err := mb2.Update(batches[0]); err != nil {
+		t.Fatal(err)
+	}
+	data, err := mb2.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resumed MiniBatchKMeans
+	if err := resumed.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for _, batch := range batches[1:] {
+		if _, err := resumed.Update(batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !reflect.DeepEqual(mb.Counts(), resumed.Counts()) {
+		t.Errorf("counts differ. %v and %v", mb.Counts(), resumed.Counts())
+	}
+	for c := range mb.means {
+		if !reflect.DeepEqual(mb.means[c].indices, resumed.means[c].indices) || !reflect.DeepEqual(mb.means[c].values, resumed.means[c].values) {
+			t.Errorf("centroid %d differs after resuming", c)
+		}
+	}
+
+	// An unstarted clustering round trips too
+	data, err = NewMiniBatchKMeans(4).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resumed.UnmarshalBinary(data); err != nil || resumed.K != 4 || resumed.centroids != nil {
+		t.Errorf("unstarted clustering not restored. %v", err)
+	}
+
+	for i, data := range [][]byte{{}, {2}, {1, 1, 0, 2, 2}, {1, 1, 0, 2, 1, 0}} {
+		if err := resumed.UnmarshalBinary(data); err == nil {
+			t.Errorf("Test %d. Expected an error", i)
+		}
+	}
+
+	if _, err := NewMiniBatchKMeans(3).Update(vectors[:2]); err == nil {
+		t.Errorf("expected an error with a small first batch")
+	}
+}
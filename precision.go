@@ -0,0 +1,299 @@
+package sparsevector
+
+import "math"
+
+// Precision selects how sums are accumulated when calculating dot products,
+// magnitudes, means and distances.
+//
+// Value is float32, and summing tens of thousands of float32 products in
+// float32 loses enough precision to give visibly inaccurate cosines. The
+// higher precisions are slower, and don't use the SIMD dot product kernels.
+type Precision byte
+
+const (
+	// PrecisionFloat32 accumulates in float32. This is the default, and the
+	// fastest.
+	PrecisionFloat32 Precision = iota
+	// PrecisionFloat64 accumulates in float64. Products of float32 values are
+	// exact in float64, so the only error is in the summation.
+	PrecisionFloat64
+	// PrecisionCompensated accumulates in float64 using Neumaier's variant of
+	// Kahan summation, which also tracks the error lost in the summation.
+	PrecisionCompensated
+)
+
+// maxPrecision returns the higher of two precisions
+func maxPrecision(p1, p2 Precision) Precision {
+	if p1 > p2 {
+		return p1
+	}
+	return p2
+}
+
+// summer sums float64s, with compensation if required
+type summer struct {
+	compensated bool
+	sum         float64
+	c           float64
+}
+
+func newSummer(p Precision) summer {
+	return summer{compensated: p == PrecisionCompensated}
+}
+
+func (s *summer) add(x float64) {
+	if !s.compensated {
+		s.sum += x
+		return
+	}
+	t := s.sum + x
+	if math.Abs(s.sum) >= math.Abs(x) {
+		s.c += (s.sum - t) + x
+	} else {
+		s.c += (x - t) + s.sum
+	}
+	s.sum = t
+}
+
+func (s *summer) result() float64 {
+	return s.sum + s.c
+}
+
+// SetPrecision sets the precision used by Dot, Mag, Mean and Distance on
+// this vector. When two vectors are combined the higher of their precisions is
+// used. Vectors created by Add and Sub have the default precision.
+func (sv *SparseVectorUint32) SetPrecision(p Precision) {
+	sv.precision = p
+	sv.magClean = false
+}
+
+// DotPrecision calculates the dot product of this vector and another with
+// the precision given. If the other vector is not a SparseVectorUint32 this
+// is the same as Dot.
+func (sv1 *SparseVectorUint32) DotPrecision(sv2in Vector, p Precision) Value {
+	sv2, ok := sv2in.(*SparseVectorUint32)
+	if !ok {
+		return sv1.Dot(sv2in)
+	}
+	if p == PrecisionFloat32 {
+		return dotUint32(sv1.indices, sv1.values, sv2.indices, sv2.values)
+	}
+
+	acc := newSummer(p)
+	var i1, i2 int
+	for i1 < len(sv1.indices) && i2 < len(sv2.indices) {
+		if sv1.indices[i1] < sv2.indices[i2] {
+			i1++
+		} else if sv2.indices[i2] < sv1.indices[i1] {
+			i2++
+		} else {
+			acc.add(float64(sv1.values[i1]) * float64(sv2.values[i2]))
+			i1++
+			i2++
+		}
+	}
+	return Value(acc.result())
+}
+
+// MagPrecision calculates the magnitude of the vector with the precision
+// given. Unlike Mag the result is not cached.
+func (sv *SparseVectorUint32) MagPrecision(p Precision) Value {
+	return magPrecision(sv.values, p)
+}
+
+// MeanPrecision calculates the mean of the values present with the precision
+// given
+func (sv *SparseVectorUint32) MeanPrecision(p Precision) Value {
+	return meanPrecision(sv.values, p)
+}
+
+// CosPrecision calculates the cosine of the angle between this vector and
+// another with the precision given
+func (sv1 *SparseVectorUint32) CosPrecision(sv2in Vector, p Precision) Value {
+	sv2, ok := sv2in.(*SparseVectorUint32)
+	if !ok {
+		return sv1.Cos(sv2in)
+	}
+	return Value(float64(sv1.DotPrecision(sv2, p)) / (float64(sv1.MagPrecision(p)) * float64(sv2.MagPrecision(p))))
+}
+
+// Distance calculates the Euclidean distance between this vector and another
+func (sv1 *SparseVectorUint32) Distance(sv2 *SparseVectorUint32) Value {
+	return sv1.DistancePrecision(sv2, maxPrecision(sv1.precision, sv2.precision))
+}
+
+// DistancePrecision calculates the Euclidean distance between this vector and
+// another with the precision given
+func (sv1 *SparseVectorUint32) DistancePrecision(sv2 *SparseVectorUint32, p Precision) Value {
+	if p == PrecisionFloat32 {
+		var sum Value
+		var i1, i2 int
+		for i1 < len(sv1.indices) || i2 < len(sv2.indices) {
+			var d Value
+			if i2 == len(sv2.indices) || (i1 < len(sv1.indices) && sv1.indices[i1] < sv2.indices[i2]) {
+				d = sv1.values[i1]
+				i1++
+			} else if i1 == len(sv1.indices) || sv2.indices[i2] < sv1.indices[i1] {
+				d = sv2.values[i2]
+				i2++
+			} else {
+				d = sv1.values[i1] - sv2.values[i2]
+				i1++
+				i2++
+			}
+			sum += d * d
+		}
+		return Value(math.Sqrt(float64(sum)))
+	}
+
+	acc := newSummer(p)
+	var i1, i2 int
+	for i1 < len(sv1.indices) || i2 < len(sv2.indices) {
+		var d float64
+		if i2 == len(sv2.indices) || (i1 < len(sv1.indices) && sv1.indices[i1] < sv2.indices[i2]) {
+			d = float64(sv1.values[i1])
+			i1++
+		} else if i1 == len(sv1.indices) || sv2.indices[i2] < sv1.indices[i1] {
+			d = float64(sv2.values[i2])
+			i2++
+		} else {
+			d = float64(sv1.values[i1]) - float64(sv2.values[i2])
+			i1++
+			i2++
+		}
+		acc.add(d * d)
+	}
+	return Value(math.Sqrt(acc.result()))
+}
+
+// SetPrecision sets the precision used by Dot, Mag, Mean and Distance on
+// this vector. When two vectors are combined the higher of their precisions is
+// used. Vectors created by Add and Sub have the default precision.
+func (sv *GenSparseVector) SetPrecision(p Precision) {
+	sv.precision = p
+	sv.magClean = false
+}
+
+// DotPrecision calculates the dot product of this vector and another
+// GenSparseVector with the precision given
+func (sv1 *GenSparseVector) DotPrecision(svi2 Vector, p Precision) Value {
+	sv2 := svi2.(*GenSparseVector)
+	if p == PrecisionFloat32 {
+		return sv1.dot(sv2)
+	}
+
+	acc := newSummer(p)
+	var i1, i2 int
+	sv1l := sv1.index.Len()
+	sv2l := sv2.index.Len()
+	for i1 < sv1l && i2 < sv2l {
+		if sv1.index.LessThanOther(i1, sv2.index, i2) {
+			i1++
+		} else if sv2.index.LessThanOther(i2, sv1.index, i1) {
+			i2++
+		} else {
+			acc.add(float64(sv1.values[i1]) * float64(sv2.values[i2]))
+			i1++
+			i2++
+		}
+	}
+	return Value(acc.result())
+}
+
+// MagPrecision calculates the magnitude of the vector with the precision
+// given. Unlike Mag the result is not cached.
+func (sv *GenSparseVector) MagPrecision(p Precision) Value {
+	return magPrecision(sv.values, p)
+}
+
+// MeanPrecision calculates the mean of the values present with the precision
+// given
+func (sv *GenSparseVector) MeanPrecision(p Precision) Value {
+	return meanPrecision(sv.values, p)
+}
+
+// CosPrecision calculates the cosine of the angle between this vector and
+// another GenSparseVector with the precision given
+func (sv1 *GenSparseVector) CosPrecision(svi2 Vector, p Precision) Value {
+	sv2 := svi2.(*GenSparseVector)
+	return Value(float64(sv1.DotPrecision(sv2, p)) / (float64(sv1.MagPrecision(p)) * float64(sv2.MagPrecision(p))))
+}
+
+// Distance calculates the Euclidean distance between this vector and another
+func (sv1 *GenSparseVector) Distance(sv2 *GenSparseVector) Value {
+	return sv1.DistancePrecision(sv2, maxPrecision(sv1.precision, sv2.precision))
+}
+
+// DistancePrecision calculates the Euclidean distance between this vector and
+// another with the precision given
+func (sv1 *GenSparseVector) DistancePrecision(sv2 *GenSparseVector, p Precision) Value {
+	acc := newSummer(p)
+	var sum Value
+	add := func(d Value) {
+		if p == PrecisionFloat32 {
+			sum += d * d
+		} else {
+			acc.add(float64(d) * float64(d))
+		}
+	}
+
+	var i1, i2 int
+	sv1l := sv1.index.Len()
+	sv2l := sv2.index.Len()
+	for i1 < sv1l || i2 < sv2l {
+		if i2 == sv2l || (i1 < sv1l && sv1.index.LessThanOther(i1, sv2.index, i2)) {
+			add(sv1.values[i1])
+			i1++
+		} else if i1 == sv1l || sv2.index.LessThanOther(i2, sv1.index, i1) {
+			add(sv2.values[i2])
+			i2++
+		} else {
+			if p == PrecisionFloat32 {
+				add(sv1.values[i1] - sv2.values[i2])
+			} else {
+				d := float64(sv1.values[i1]) - float64(sv2.values[i2])
+				acc.add(d * d)
+			}
+			i1++
+			i2++
+		}
+	}
+	if p == PrecisionFloat32 {
+		return Value(math.Sqrt(float64(sum)))
+	}
+	return Value(math.Sqrt(acc.result()))
+}
+
+// magPrecision calculates the magnitude of a list of values. At
+// PrecisionFloat32 this matches the Mag methods.
+func magPrecision(values []Value, p Precision) Value {
+	if p == PrecisionFloat32 {
+		var magsq Value
+		for _, val := range values {
+			magsq += val * val
+		}
+		return Value(math.Sqrt(float64(magsq)))
+	}
+	acc := newSummer(p)
+	for _, val := range values {
+		acc.add(float64(val) * float64(val))
+	}
+	return Value(math.Sqrt(acc.result()))
+}
+
+// meanPrecision calculates the mean of a list of values. At PrecisionFloat32
+// this matches the Mean methods.
+func meanPrecision(values []Value, p Precision) Value {
+	if p == PrecisionFloat32 {
+		var total Value
+		for _, v := range values {
+			total += v
+		}
+		return total / Value(len(values))
+	}
+	acc := newSummer(p)
+	for _, v := range values {
+		acc.add(float64(v))
+	}
+	return Value(acc.result() / float64(len(values)))
+}
@@ -0,0 +1,117 @@
+package sparsevector
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestPrecisionDot(t *testing.T) {
+	// Products sum to exactly 1, but the 1 is lost in float64 unless the sum
+	// is compensated
+	sv1 := NewSparseVectorUint32([]uint32{1, 2, 3}, []Value{1e8, 1, 1e8})
+	sv2 := NewSparseVectorUint32([]uint32{1, 2, 3}, []Value{1e8, 1, -1e8})
+	gsv1 := NewGenSparseVector(IntIndex{1, 2, 3}, []Value{1e8, 1, 1e8})
+	gsv2 := NewGenSparseVector(IntIndex{1, 2, 3}, []Value{1e8, 1, -1e8})
+
+	tests := []struct {
+		p   Precision
+		exp Value
+	}{
+		{PrecisionFloat32, 0},
+		{PrecisionFloat64, 0},
+		{PrecisionCompensated, 1},
+	}
+	for i, test := range tests {
+		if dp := sv1.DotPrecision(sv2, test.p); dp != test.exp {
+			t.Errorf("Test %d. Dot is %f, expected %f", i, dp, test.exp)
+		}
+		if dp := gsv1.DotPrecision(gsv2, test.p); dp != test.exp {
+			t.Errorf("Test %d. GenSparseVector Dot is %f, expected %f", i, dp, test.exp)
+		}
+
+		// Per vector precision
+		sv1.SetPrecision(test.p)
+		gsv2.SetPrecision(test.p)
+		if dp := sv1.Dot(sv2); dp != test.exp {
+			t.Errorf("Test %d. Dot with vector precision is %f, expected %f", i, dp, test.exp)
+		}
+		if dp := gsv1.Dot(gsv2); dp != test.exp {
+			t.Errorf("Test %d. GenSparseVector Dot with vector precision is %f, expected %f", i, dp, test.exp)
+		}
+	}
+}
+
+func TestPrecisionLongVectors(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	l := 50000
+	indices := make([]uint32, l)
+	values1 := make([]Value, l)
+	values2 := make([]Value, l)
+	var dot, magsq, sum float64
+	for i := range indices {
+		indices[i] = uint32(i)
+		values1[i] = Value(rnd.Float64())
+		values2[i] = Value(rnd.Float64())
+		dot += float64(values1[i]) * float64(values2[i])
+		magsq += float64(values1[i]) * float64(values1[i])
+		sum += float64(values1[i])
+	}
+	sv1 := NewSparseVectorUint32(indices, values1)
+	sv2 := NewSparseVectorUint32(append([]uint32(nil), indices...), values2)
+
+	relErr := func(have Value, exp float64) float64 {
+		return math.Abs(float64(have)-exp) / exp
+	}
+	for _, p := range []Precision{PrecisionFloat64, PrecisionCompensated} {
+		// The result is the float64 value rounded to float32
+		if e := relErr(sv1.DotPrecision(sv2, p), dot); e > 1e-7 {
+			t.Errorf("Precision %d. Dot relative error %g", p, e)
+		}
+		if e := relErr(sv1.MagPrecision(p), math.Sqrt(magsq)); e > 1e-7 {
+			t.Errorf("Precision %d. Mag relative error %g", p, e)
+		}
+		if e := relErr(sv1.MeanPrecision(p), sum/float64(l)); e > 1e-7 {
+			t.Errorf("Precision %d. Mean relative error %g", p, e)
+		}
+	}
+	if e32, e64 := relErr(sv1.Dot(sv2), dot), relErr(sv1.DotPrecision(sv2, PrecisionFloat64), dot); e32 <= e64 {
+		t.Errorf("float32 Dot unexpectedly accurate. Relative error %g, float64 %g", e32, e64)
+	}
+
+	// The vector precision is used by Mag and Mean
+	sv1.SetPrecision(PrecisionCompensated)
+	if sv1.Mag() != sv1.MagPrecision(PrecisionCompensated) || sv1.Mean() != sv1.MeanPrecision(PrecisionCompensated) {
+		t.Errorf("vector precision not used")
+	}
+	if cos := sv1.CosPrecision(sv1, PrecisionCompensated); math.Abs(float64(cos)-1) > 1e-7 {
+		t.Errorf("Cos with self is %f", cos)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	sv1 := NewSparseVectorUint32([]uint32{1, 2, 4}, []Value{1, 2, 3})
+	sv2 := NewSparseVectorUint32([]uint32{2, 3, 4}, []Value{4, 2, 3})
+	gsv1 := NewGenSparseVector(StringIndex{"a", "b", "d"}, []Value{1, 2, 3})
+	gsv2 := NewGenSparseVector(StringIndex{"b", "c", "d"}, []Value{4, 2, 3})
+	// (1)^2 + (2-4)^2 + (2)^2 + 0
+	exp := Value(3)
+
+	for _, p := range []Precision{PrecisionFloat32, PrecisionFloat64, PrecisionCompensated} {
+		if d := sv1.DistancePrecision(sv2, p); d != exp {
+			t.Errorf("Precision %d. Distance is %f, expected %f", p, d, exp)
+		}
+		if d := sv2.DistancePrecision(sv1, p); d != exp {
+			t.Errorf("Precision %d. Reverse distance is %f, expected %f", p, d, exp)
+		}
+		if d := gsv1.DistancePrecision(gsv2, p); d != exp {
+			t.Errorf("Precision %d. GenSparseVector distance is %f, expected %f", p, d, exp)
+		}
+	}
+	if d := sv1.Distance(sv1); d != 0 {
+		t.Errorf("Distance to self is %f", d)
+	}
+	if d := gsv1.Distance(NewGenSparseVector(StringIndex{}, []Value{})); d != gsv1.Mag() {
+		t.Errorf("Distance to empty vector is %f, expected %f", d, gsv1.Mag())
+	}
+}
@@ -0,0 +1,592 @@
+package sparsevector
+
+import "math"
+
+// Int8SparseVector is a sparse vector whose values are stored as int8 with a
+// single scale for the whole vector, taking a quarter of the space of float32
+// values. Each value is the stored int8 times the scale. Indices are uint32,
+// sorted as in SparseVectorUint32.
+//
+// Dot and Cos work against any of the quantized vectors and
+// SparseVectorUint32, converting values as they are matched rather than
+// decompressing the whole vector. The dot product of two Int8SparseVectors is
+// summed as integers.
+type Int8SparseVector struct {
+	indices  []uint32
+	values   []int8
+	scale    Value
+	mag      Value
+	magClean bool
+}
+
+// Float16SparseVector is a sparse vector whose values are stored as IEEE 754
+// half precision floats. These have 11 bits of precision, and a range of about
+// 6e-8 to 65504.
+type Float16SparseVector struct {
+	indices  []uint32
+	values   []uint16
+	mag      Value
+	magClean bool
+}
+
+// BFloat16SparseVector is a sparse vector whose values are stored as bfloat16.
+// These are float32 with the bottom 16 bits removed, so have the full range of
+// float32 but only 8 bits of precision.
+type BFloat16SparseVector struct {
+	indices  []uint32
+	values   []uint16
+	mag      Value
+	magClean bool
+}
+
+// quantizedValues gives access to the values of a vector, converted to Value
+type quantizedValues interface {
+	at(i int) Value
+}
+
+// valueList is a list of unquantized values
+type valueList []Value
+
+func (v valueList) at(i int) Value { return v[i] }
+
+// NewInt8SparseVector creates an Int8SparseVector from a SparseVectorUint32.
+// The scale is chosen so that the largest value in magnitude is stored as 127
+// or -127, and the other values are rounded to the nearest step.
+func NewInt8SparseVector(sv *SparseVectorUint32) *Int8SparseVector {
+	q := &Int8SparseVector{
+		indices: append([]uint32(nil), sv.indices...),
+		values:  make([]int8, len(sv.values)),
+	}
+	var max float64
+	for _, v := range sv.values {
+		max = math.Max(max, math.Abs(float64(v)))
+	}
+	if max == 0 {
+		return q
+	}
+	q.scale = Value(max / 127)
+	for i, v := range sv.values {
+		q.values[i] = int8(math.Round(float64(v) / float64(q.scale)))
+	}
+	debugCheck(q)
+	return q
+}
+
+// NewFloat16SparseVector creates a Float16SparseVector from a
+// SparseVectorUint32. Values are rounded to the nearest float16, and values
+// too large for float16 become infinite.
+func NewFloat16SparseVector(sv *SparseVectorUint32) *Float16SparseVector {
+	q := &Float16SparseVector{
+		indices: append([]uint32(nil), sv.indices...),
+		values:  make([]uint16, len(sv.values)),
+	}
+	for i, v := range sv.values {
+		q.values[i] = float32ToFloat16(float32(v))
+	}
+	debugCheck(q)
+	return q
+}
+
+// NewBFloat16SparseVector creates a BFloat16SparseVector from a
+// SparseVectorUint32. Values are rounded to the nearest bfloat16.
+func NewBFloat16SparseVector(sv *SparseVectorUint32) *BFloat16SparseVector {
+	q := &BFloat16SparseVector{
+		indices: append([]uint32(nil), sv.indices...),
+		values:  make([]uint16, len(sv.values)),
+	}
+	for i, v := range sv.values {
+		q.values[i] = float32ToBFloat16(float32(v))
+	}
+	debugCheck(q)
+	return q
+}
+
+func (q *Int8SparseVector) at(i int) Value     { return Value(q.values[i]) * q.scale }
+func (q *Float16SparseVector) at(i int) Value  { return Value(float16ToFloat32(q.values[i])) }
+func (q *BFloat16SparseVector) at(i int) Value { return Value(bfloat16ToFloat32(q.values[i])) }
+
+// Len returns the number of entries in the vector
+func (q *Int8SparseVector) Len() int { return len(q.indices) }
+
+// Len returns the number of entries in the vector
+func (q *Float16SparseVector) Len() int { return len(q.indices) }
+
+// Len returns the number of entries in the vector
+func (q *BFloat16SparseVector) Len() int { return len(q.indices) }
+
+// Scale returns the value that the stored int8 values are multiplied by
+func (q *Int8SparseVector) Scale() Value { return q.scale }
+
+// ToSparseVector converts the vector back to a SparseVectorUint32
+func (q *Int8SparseVector) ToSparseVector() *SparseVectorUint32 {
+	return quantizedToSparseVector(q.indices, q)
+}
+
+// ToSparseVector converts the vector back to a SparseVectorUint32
+func (q *Float16SparseVector) ToSparseVector() *SparseVectorUint32 {
+	return quantizedToSparseVector(q.indices, q)
+}
+
+// ToSparseVector converts the vector back to a SparseVectorUint32
+func (q *BFloat16SparseVector) ToSparseVector() *SparseVectorUint32 {
+	return quantizedToSparseVector(q.indices, q)
+}
+
+func quantizedToSparseVector(indices []uint32, qv quantizedValues) *SparseVectorUint32 {
+	sv := &SparseVectorUint32{
+		indices: append([]uint32(nil), indices...),
+		values:  make([]Value, len(indices)),
+	}
+	for i := range sv.values {
+		sv.values[i] = qv.at(i)
+	}
+	return sv
+}
+
+// Iter lets you iterate over the members of the sparse vector in index order
+func (q *Int8SparseVector) Iter(f func(index uint32, value Value)) {
+	quantizedIter(q.indices, q, f)
+}
+
+// Iter lets you iterate over the members of the sparse vector in index order
+func (q *Float16SparseVector) Iter(f func(index uint32, value Value)) {
+	quantizedIter(q.indices, q, f)
+}
+
+// Iter lets you iterate over the members of the sparse vector in index order
+func (q *BFloat16SparseVector) Iter(f func(index uint32, value Value)) {
+	quantizedIter(q.indices, q, f)
+}
+
+func quantizedIter(indices []uint32, qv quantizedValues, f func(index uint32, value Value)) {
+	for i, index := range indices {
+		f(index, qv.at(i))
+	}
+}
+
+// Mag returns the magnitude of the vector. It is calculated lazily and cached.
+func (q *Int8SparseVector) Mag() Value {
+	if !q.magClean {
+		var magsq int64
+		for _, v := range q.values {
+			magsq += int64(v) * int64(v)
+		}
+		q.mag = Value(math.Sqrt(float64(magsq)) * math.Abs(float64(q.scale)))
+		q.magClean = true
+	}
+	return q.mag
+}
+
+// Mag returns the magnitude of the vector. It is calculated lazily and cached.
+func (q *Float16SparseVector) Mag() Value {
+	if !q.magClean {
+		q.mag = quantizedMag(len(q.values), q)
+		q.magClean = true
+	}
+	return q.mag
+}
+
+// Mag returns the magnitude of the vector. It is calculated lazily and cached.
+func (q *BFloat16SparseVector) Mag() Value {
+	if !q.magClean {
+		q.mag = quantizedMag(len(q.values), q)
+		q.magClean = true
+	}
+	return q.mag
+}
+
+func quantizedMag(l int, qv quantizedValues) Value {
+	var magsq Value
+	for i := 0; i < l; i++ {
+		v := qv.at(i)
+		magsq += v * v
+	}
+	return Value(math.Sqrt(float64(magsq)))
+}
+
+// quantizedParts returns the indices and values of any of the vectors a
+// quantized vector can be combined with
+func quantizedParts(v Vector) ([]uint32, quantizedValues) {
+	switch v := v.(type) {
+	case *SparseVectorUint32:
+		return v.indices, valueList(v.values)
+	case *Int8SparseVector:
+		return v.indices, v
+	case *Float16SparseVector:
+		return v.indices, v
+	case *BFloat16SparseVector:
+		return v.indices, v
+	}
+	panic("sparsevector: quantized vectors can only be combined with quantized vectors or SparseVectorUint32")
+}
+
+// quantizedDot calculates a dot product, converting values as they are
+// matched. It works with any pair of vectors, but calls at for each value, so
+// Dot uses the loops below for the common pairs.
+func quantizedDot(ai []uint32, av quantizedValues, bi []uint32, bv quantizedValues) Value {
+	var dp Value
+	var i1, i2 int
+	for i1 < len(ai) && i2 < len(bi) {
+		if ai[i1] < bi[i2] {
+			i1++
+		} else if bi[i2] < ai[i1] {
+			i2++
+		} else {
+			dp += av.at(i1) * bv.at(i2)
+			i1++
+			i2++
+		}
+	}
+	return dp
+}
+
+// int8Dot is the dot product of int8 values and Values, before the int8
+// values are scaled
+func int8Dot(ai []uint32, av []int8, bi []uint32, bv []Value) Value {
+	var dp Value
+	var i1, i2 int
+	for i1 < len(ai) && i2 < len(bi) {
+		if ai[i1] < bi[i2] {
+			i1++
+		} else if bi[i2] < ai[i1] {
+			i2++
+		} else {
+			dp += Value(av[i1]) * bv[i2]
+			i1++
+			i2++
+		}
+	}
+	return dp
+}
+
+// int8PairDot is the dot product of two lists of int8 values, summed as
+// integers
+func int8PairDot(ai []uint32, av []int8, bi []uint32, bv []int8) int64 {
+	var dp int64
+	var i1, i2 int
+	for i1 < len(ai) && i2 < len(bi) {
+		if ai[i1] < bi[i2] {
+			i1++
+		} else if bi[i2] < ai[i1] {
+			i2++
+		} else {
+			dp += int64(av[i1]) * int64(bv[i2])
+			i1++
+			i2++
+		}
+	}
+	return dp
+}
+
+// float16Dot is the dot product of float16 values and Values
+func float16Dot(ai []uint32, av []uint16, bi []uint32, bv []Value) Value {
+	var dp Value
+	var i1, i2 int
+	for i1 < len(ai) && i2 < len(bi) {
+		if ai[i1] < bi[i2] {
+			i1++
+		} else if bi[i2] < ai[i1] {
+			i2++
+		} else {
+			dp += Value(float16ToFloat32(av[i1])) * bv[i2]
+			i1++
+			i2++
+		}
+	}
+	return dp
+}
+
+// float16PairDot is the dot product of two lists of float16 values
+func float16PairDot(ai []uint32, av []uint16, bi []uint32, bv []uint16) Value {
+	var dp Value
+	var i1, i2 int
+	for i1 < len(ai) && i2 < len(bi) {
+		if ai[i1] < bi[i2] {
+			i1++
+		} else if bi[i2] < ai[i1] {
+			i2++
+		} else {
+			dp += Value(float16ToFloat32(av[i1])) * Value(float16ToFloat32(bv[i2]))
+			i1++
+			i2++
+		}
+	}
+	return dp
+}
+
+// bfloat16Dot is the dot product of bfloat16 values and Values
+func bfloat16Dot(ai []uint32, av []uint16, bi []uint32, bv []Value) Value {
+	var dp Value
+	var i1, i2 int
+	for i1 < len(ai) && i2 < len(bi) {
+		if ai[i1] < bi[i2] {
+			i1++
+		} else if bi[i2] < ai[i1] {
+			i2++
+		} else {
+			dp += Value(bfloat16ToFloat32(av[i1])) * bv[i2]
+			i1++
+			i2++
+		}
+	}
+	return dp
+}
+
+// bfloat16PairDot is the dot product of two lists of bfloat16 values
+func bfloat16PairDot(ai []uint32, av []uint16, bi []uint32, bv []uint16) Value {
+	var dp Value
+	var i1, i2 int
+	for i1 < len(ai) && i2 < len(bi) {
+		if ai[i1] < bi[i2] {
+			i1++
+		} else if bi[i2] < ai[i1] {
+			i2++
+		} else {
+			dp += Value(bfloat16ToFloat32(av[i1])) * Value(bfloat16ToFloat32(bv[i2]))
+			i1++
+			i2++
+		}
+	}
+	return dp
+}
+
+// Dot calculates the dot product of this vector and another, which may be
+// any of the quantized vectors or a SparseVectorUint32
+func (q *Int8SparseVector) Dot(v Vector) Value {
+	switch v := v.(type) {
+	case *Int8SparseVector:
+		// Sum the products of the stored values, and scale once at the end
+		dp := int8PairDot(q.indices, q.values, v.indices, v.values)
+		return Value(float64(dp) * float64(q.scale) * float64(v.scale))
+	case *SparseVectorUint32:
+		return int8Dot(q.indices, q.values, v.indices, v.values) * q.scale
+	}
+	indices, values := quantizedParts(v)
+	return quantizedDot(q.indices, q, indices, values)
+}
+
+// Dot calculates the dot product of this vector and another, which may be
+// any of the quantized vectors or a SparseVectorUint32
+func (q *Float16SparseVector) Dot(v Vector) Value {
+	switch v := v.(type) {
+	case *Float16SparseVector:
+		return float16PairDot(q.indices, q.values, v.indices, v.values)
+	case *SparseVectorUint32:
+		return float16Dot(q.indices, q.values, v.indices, v.values)
+	}
+	indices, values := quantizedParts(v)
+	return quantizedDot(q.indices, q, indices, values)
+}
+
+// Dot calculates the dot product of this vector and another, which may be
+// any of the quantized vectors or a SparseVectorUint32
+func (q *BFloat16SparseVector) Dot(v Vector) Value {
+	switch v := v.(type) {
+	case *BFloat16SparseVector:
+		return bfloat16PairDot(q.indices, q.values, v.indices, v.values)
+	case *SparseVectorUint32:
+		return bfloat16Dot(q.indices, q.values, v.indices, v.values)
+	}
+	indices, values := quantizedParts(v)
+	return quantizedDot(q.indices, q, indices, values)
+}
+
+// Cos calculates the cosine of the angle between this vector and another,
+// which may be any of the quantized vectors or a SparseVectorUint32
+func (q *Int8SparseVector) Cos(v Vector) Value {
+	return q.Dot(v) / (q.Mag() * v.Mag())
+}
+
+// Cos calculates the cosine of the angle between this vector and another,
+// which may be any of the quantized vectors or a SparseVectorUint32
+func (q *Float16SparseVector) Cos(v Vector) Value {
+	return q.Dot(v) / (q.Mag() * v.Mag())
+}
+
+// Cos calculates the cosine of the angle between this vector and another,
+// which may be any of the quantized vectors or a SparseVectorUint32
+func (q *BFloat16SparseVector) Cos(v Vector) Value {
+	return q.Dot(v) / (q.Mag() * v.Mag())
+}
+
+// quantizedOp runs an operation on two vectors, returning a SparseVectorUint32
+func quantizedOp(v1, v2 Vector, op ValueOp) *SparseVectorUint32 {
+	ai, av := quantizedParts(v1)
+	bi, bv := quantizedParts(v2)
+	sv := &SparseVectorUint32{
+		indices: make([]uint32, 0, len(ai)+len(bi)),
+		values:  make([]Value, 0, len(ai)+len(bi)),
+	}
+	var i1, i2 int
+	for i1 < len(ai) || i2 < len(bi) {
+		if i2 == len(bi) || (i1 < len(ai) && ai[i1] < bi[i2]) {
+			sv.indices = append(sv.indices, ai[i1])
+			sv.values = append(sv.values, op(av.at(i1), 0))
+			i1++
+		} else if i1 == len(ai) || bi[i2] < ai[i1] {
+			sv.indices = append(sv.indices, bi[i2])
+			sv.values = append(sv.values, op(0, bv.at(i2)))
+			i2++
+		} else {
+			sv.indices = append(sv.indices, ai[i1])
+			sv.values = append(sv.values, op(av.at(i1), bv.at(i2)))
+			i1++
+			i2++
+		}
+	}
+	return sv
+}
+
+// Add adds a vector to this one. The result is quantized again, so is an
+// Int8SparseVector with a new scale.
+func (q *Int8SparseVector) Add(v Vector) Vector {
+	return NewInt8SparseVector(quantizedOp(q, v, AddOp))
+}
+
+// Sub subtracts a vector from this one. The result is quantized again, so is
+// an Int8SparseVector with a new scale.
+func (q *Int8SparseVector) Sub(v Vector) Vector {
+	return NewInt8SparseVector(quantizedOp(q, v, SubOp))
+}
+
+// Add adds a vector to this one. The result is rounded to a
+// Float16SparseVector.
+func (q *Float16SparseVector) Add(v Vector) Vector {
+	return NewFloat16SparseVector(quantizedOp(q, v, AddOp))
+}
+
+// Sub subtracts a vector from this one. The result is rounded to a
+// Float16SparseVector.
+func (q *Float16SparseVector) Sub(v Vector) Vector {
+	return NewFloat16SparseVector(quantizedOp(q, v, SubOp))
+}
+
+// Add adds a vector to this one. The result is rounded to a
+// BFloat16SparseVector.
+func (q *BFloat16SparseVector) Add(v Vector) Vector {
+	return NewBFloat16SparseVector(quantizedOp(q, v, AddOp))
+}
+
+// Sub subtracts a vector from this one. The result is rounded to a
+// BFloat16SparseVector.
+func (q *BFloat16SparseVector) Sub(v Vector) Vector {
+	return NewBFloat16SparseVector(quantizedOp(q, v, SubOp))
+}
+
+// Mult multiplies the vector by a constant. Only the scale changes, so no
+// precision is lost. The vector is modified in place.
+func (q *Int8SparseVector) Mult(l Value) {
+	q.scale *= l
+	q.magClean = false
+	debugCheck(q)
+}
+
+// Mult multiplies the vector by a constant. Each value is rounded to float16
+// again. The vector is modified in place.
+func (q *Float16SparseVector) Mult(l Value) {
+	for i, v := range q.values {
+		q.values[i] = float32ToFloat16(float16ToFloat32(v) * float32(l))
+	}
+	q.magClean = false
+	debugCheck(q)
+}
+
+// Mult multiplies the vector by a constant. Each value is rounded to bfloat16
+// again. The vector is modified in place.
+func (q *BFloat16SparseVector) Mult(l Value) {
+	for i, v := range q.values {
+		q.values[i] = float32ToBFloat16(bfloat16ToFloat32(v) * float32(l))
+	}
+	q.magClean = false
+	debugCheck(q)
+}
+
+// float32ToFloat16 converts a float32 to the bits of the nearest IEEE 754
+// half precision float, rounding ties to even
+func float32ToFloat16(f float32) uint16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int(b>>23) & 0xff
+	mant := b & 0x7fffff
+
+	if exp == 0xff {
+		if mant != 0 {
+			// NaN
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	}
+
+	e := exp - 127 + 15
+	if e >= 0x1f {
+		// Too large, so infinite
+		return sign | 0x7c00
+	}
+	if e <= 0 {
+		// Subnormal, or too small so zero
+		if e < -10 {
+			return sign
+		}
+		mant |= 0x800000
+		shift := uint(14 - e)
+		half := mant >> shift
+		rem := mant & (1<<shift - 1)
+		halfway := uint32(1) << (shift - 1)
+		if rem > halfway || (rem == halfway && half&1 == 1) {
+			// This can carry into the exponent, giving the smallest normal
+			half++
+		}
+		return sign | uint16(half)
+	}
+
+	half := uint32(e)<<10 | mant>>13
+	rem := mant & 0x1fff
+	if rem > 0x1000 || (rem == 0x1000 && half&1 == 1) {
+		// This can carry into the exponent, and up to infinity
+		half++
+	}
+	return sign | uint16(half)
+}
+
+// float16ToFloat32 converts the bits of a half precision float to a float32
+func float16ToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+
+	switch exp {
+	case 0:
+		// Zero or subnormal, mant * 2^-24
+		f := float32(mant) / (1 << 24)
+		if sign != 0 {
+			return -f
+		}
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	}
+	return math.Float32frombits(sign | (exp-15+127)<<23 | mant<<13)
+}
+
+// float32ToBFloat16 converts a float32 to the nearest bfloat16, rounding ties
+// to even
+func float32ToBFloat16(f float32) uint16 {
+	b := math.Float32bits(f)
+	if b&0x7fffffff > 0x7f800000 {
+		// Keep NaNs as quiet NaNs
+		return uint16(b>>16) | 0x40
+	}
+	b += 0x7fff + (b>>16)&1
+	return uint16(b >> 16)
+}
+
+// bfloat16ToFloat32 converts a bfloat16 to a float32
+func bfloat16ToFloat32(h uint16) float32 {
+	return math.Float32frombits(uint32(h) << 16)
+}
+
+var (
+	_ Vector = (*Int8SparseVector)(nil)
+	_ Vector = (*Float16SparseVector)(nil)
+	_ Vector = (*BFloat16SparseVector)(nil)
+)
@@ -0,0 +1,231 @@
+package sparsevector
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func BenchmarkInt8SparseVector10000(b *testing.B) {
+	v1 := NewInt8SparseVector(genRandomSparseVector(10000))
+	v2 := NewInt8SparseVector(genRandomSparseVector(10000))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var total Value
+	for i := 0; i < b.N; i++ {
+		result := v1.Cos(v2)
+		total += result
+	}
+}
+
+func BenchmarkFloat16SparseVector10000(b *testing.B) {
+	v1 := NewFloat16SparseVector(genRandomSparseVector(10000))
+	v2 := NewFloat16SparseVector(genRandomSparseVector(10000))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var total Value
+	for i := 0; i < b.N; i++ {
+		result := v1.Cos(v2)
+		total += result
+	}
+}
+
+func BenchmarkInt8SparseVectorUint32Dot10000(b *testing.B) {
+	v1 := NewInt8SparseVector(genRandomSparseVector(10000))
+	v2 := genRandomSparseVector(10000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var total Value
+	for i := 0; i < b.N; i++ {
+		total += v1.Dot(v2)
+	}
+}
+
+func BenchmarkFloat16SparseVectorUint32Dot10000(b *testing.B) {
+	v1 := NewFloat16SparseVector(genRandomSparseVector(10000))
+	v2 := genRandomSparseVector(10000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var total Value
+	for i := 0; i < b.N; i++ {
+		total += v1.Dot(v2)
+	}
+}
+
+func TestFloat16Conversion(t *testing.T) {
+	tests := []struct {
+		f float32
+		h uint16
+	}{
+		{0, 0},
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{65504, 0x7bff},
+		{65520, 0x7c00},
+		{float32(math.Inf(-1)), 0xfc00},
+		{1.0 / (1 << 24), 0x0001},
+		{1.0 / (1 << 25), 0},
+		{1.5 / (1 << 25), 0x0001},
+		{1.0 / (1 << 14), 0x0400},
+		// Ties round to even
+		{1 + 1.0/2048, 0x3c00},
+		{1 + 3.0/2048, 0x3c02},
+	}
+	for i, test := range tests {
+		if h := float32ToFloat16(test.f); h != test.h {
+			t.Errorf("Test %d. %g converted to %#04x, expected %#04x", i, test.f, h, test.h)
+		}
+	}
+	if h := float32ToFloat16(float32(math.NaN())); float16ToFloat32(h) == float16ToFloat32(h) {
+		t.Errorf("NaN converted to %#04x", h)
+	}
+
+	// Every float16 converts to float32 and back exactly
+	for h := 0; h < 1<<16; h++ {
+		if h&0x7c00 == 0x7c00 && h&0x3ff != 0 {
+			continue
+		}
+		if back := float32ToFloat16(float16ToFloat32(uint16(h))); back != uint16(h) {
+			t.Fatalf("%#04x converted to %g and back to %#04x", h, float16ToFloat32(uint16(h)), back)
+		}
+	}
+}
+
+func TestBFloat16Conversion(t *testing.T) {
+	tests := []struct {
+		f float32
+		h uint16
+	}{
+		{0, 0},
+		{1, 0x3f80},
+		{-2, 0xc000},
+		{3.0e38, 0x7f62},
+		{float32(math.Inf(1)), 0x7f80},
+		// Ties round to even
+		{1 + 1.0/256, 0x3f80},
+		{1 + 3.0/256, 0x3f82},
+	}
+	for i, test := range tests {
+		if h := float32ToBFloat16(test.f); h != test.h {
+			t.Errorf("Test %d. %g converted to %#04x, expected %#04x", i, test.f, h, test.h)
+		}
+		if f := bfloat16ToFloat32(float32ToBFloat16(test.f)); math.Abs(float64(f-test.f)) > math.Abs(float64(test.f))/128 {
+			t.Errorf("Test %d. %g round trips to %g", i, test.f, f)
+		}
+	}
+}
+
+func TestQuantizedDot(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		sv1 := genSpreadSparseVector(rnd, 1+rnd.Intn(1000), 2000)
+		sv2 := genSpreadSparseVector(rnd, 1+rnd.Intn(1000), 2000)
+		sv2.Mult(-1)
+
+		vectors := []Vector{
+			sv1,
+			NewInt8SparseVector(sv1),
+			NewFloat16SparseVector(sv1),
+			NewBFloat16SparseVector(sv1),
+		}
+		others := []Vector{
+			sv2,
+			NewInt8SparseVector(sv2),
+			NewFloat16SparseVector(sv2),
+			NewBFloat16SparseVector(sv2),
+		}
+
+		exp := float64(sv1.Cos(sv2))
+		for j, v1 := range vectors {
+			for k, v2 := range others {
+				// int8 has the least precision, about 1% of the largest value
+				if cos := v1.Cos(v2); math.Abs(float64(cos)-exp) > 0.01 {
+					t.Errorf("Test %d. Cos of %T and %T is %f, expected %f", i, v1, v2, cos, exp)
+				}
+				if j > 0 {
+					// Dot uses its own loops for the common pairs, which
+					// must match the general one
+					ai, av := quantizedParts(v1)
+					bi, bv := quantizedParts(v2)
+					exp := quantizedDot(ai, av, bi, bv)
+					if dp := v1.Dot(v2); math.Abs(float64(dp-exp)) > 1e-4*math.Max(1, math.Abs(float64(exp))) {
+						t.Errorf("Test %d. Dot of %T and %T is %f, expected %f", i, v1, v2, dp, exp)
+					}
+				}
+				if j > 0 && k > 0 {
+					if dp, rev := v1.Dot(v2), v2.Dot(v1); dp != rev {
+						t.Errorf("Test %d. Dot of %T and %T is %f one way, %f the other", i, v1, v2, dp, rev)
+					}
+				}
+			}
+		}
+
+		// Values are multiples of 0.25 below 25, so float16 holds them
+		// exactly
+		f := NewFloat16SparseVector(sv1)
+		if dp, exp := f.Dot(sv2), sv1.Dot(sv2); dp != exp {
+			t.Errorf("Test %d. Float16 Dot is %f, expected %f", i, dp, exp)
+		}
+		if mag := f.Mag(); mag != sv1.Mag() {
+			t.Errorf("Test %d. Float16 Mag is %f, expected %f", i, mag, sv1.Mag())
+		}
+	}
+}
+
+func TestQuantizedOps(t *testing.T) {
+	sv1 := NewSparseVectorUint32([]uint32{1, 2, 3}, []Value{4, -127, 6})
+	sv2 := NewSparseVectorUint32([]uint32{1, 3, 4}, []Value{1, 2, 3})
+
+	q := NewInt8SparseVector(sv1)
+	if q.Scale() != 1 || q.Len() != 3 {
+		t.Errorf("scale %f, length %d", q.Scale(), q.Len())
+	}
+	sum := q.Add(NewFloat16SparseVector(sv2)).(*Int8SparseVector).ToSparseVector()
+	exp := []Value{5, -127, 8, 3}
+	for i, v := range sum.values {
+		if v != exp[i] {
+			t.Errorf("Sum not as expected. Have %v", sum.values)
+			break
+		}
+	}
+	diff := NewBFloat16SparseVector(sv1).Sub(sv2).(*BFloat16SparseVector).ToSparseVector()
+	exp = []Value{3, -127, 4, -3}
+	for i, v := range diff.values {
+		if v != exp[i] {
+			t.Errorf("Difference not as expected. Have %v", diff.values)
+			break
+		}
+	}
+
+	q.Mult(0.5)
+	var values []Value
+	q.Iter(func(index uint32, value Value) { values = append(values, value) })
+	exp = []Value{2, -63.5, 3}
+	for i, v := range values {
+		if v != exp[i] {
+			t.Errorf("Mult not as expected. Have %v", values)
+			break
+		}
+	}
+	if mag := q.Mag(); math.Abs(float64(mag)-math.Sqrt(4+63.5*63.5+9)) > 1e-4 {
+		t.Errorf("Mag after Mult is %f", mag)
+	}
+
+	h := NewFloat16SparseVector(sv1)
+	h.Mult(2)
+	if v := h.ToSparseVector().values; v[1] != -254 {
+		t.Errorf("Float16 Mult not as expected. Have %v", v)
+	}
+
+	if z := NewInt8SparseVector(NewSparseVectorUint32([]uint32{1}, []Value{0})); z.Mag() != 0 {
+		t.Errorf("zero vector has Mag %f", z.Mag())
+	}
+}
@@ -0,0 +1,201 @@
+package sparsevector
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// NearestCentroid is a nearest-centroid, or Rocchio, classifier. Each class
+// has a centroid of magnitude 1, and a vector is classified by the centroid
+// it has the highest cosine with.
+//
+// A class's centroid is the mean of the normalized vectors in the class. If
+// NegativeWeight is set, the mean of the normalized vectors in every other
+// class is subtracted from it, scaled by NegativeWeight. This pushes the
+// centroid away from indices that are common to all classes.
+type NearestCentroid struct {
+	// NegativeWeight scales the mean of the other classes' vectors subtracted
+	// from each centroid. Entries that become negative are dropped. If it is
+	// zero nothing is subtracted, which is the default.
+	NegativeWeight Value
+	// MaxCentroidSize is the maximum number of entries kept in each centroid.
+	// The entries with the largest absolute values are kept. If it is zero
+	// centroids are not pruned, which is the default.
+	MaxCentroidSize int
+
+	centroids []*SparseVectorUint32
+}
+
+// NewNearestCentroid creates a NearestCentroid classifier with the default
+// settings
+func NewNearestCentroid() *NearestCentroid {
+	return &NearestCentroid{}
+}
+
+// Fit builds a centroid for each class from vectors and their class labels.
+// Labels are numbered from 0, and a class with no vectors has an empty
+// centroid. The vectors are not modified.
+func (nc *NearestCentroid) Fit(vectors []*SparseVectorUint32, labels []int) error {
+	if len(vectors) != len(labels) {
+		return fmt.Errorf("sparsevector: have %d vectors and %d labels", len(vectors), len(labels))
+	}
+	if len(vectors) == 0 {
+		return errors.New("sparsevector: NearestCentroid needs at least one vector")
+	}
+	classes := 0
+	for _, label := range labels {
+		if label < 0 {
+			return fmt.Errorf("sparsevector: bad NearestCentroid label %d", label)
+		}
+		if label >= classes {
+			classes = label + 1
+		}
+	}
+
+	// Sum the normalized vectors in each class, and in total
+	acc := newCentroidAccumulator(vectors)
+	members := make([][]int, classes)
+	for i, label := range labels {
+		members[label] = append(members[label], i)
+	}
+	sums := make([]*SparseVectorUint32, classes)
+	for c, m := range members {
+		for _, i := range m {
+			if mag := vectors[i].Mag(); mag != 0 {
+				acc.AddScaled(vectors[i], 1/mag)
+			}
+		}
+		sums[c] = acc.Sum()
+	}
+	var total *SparseVectorUint32
+	if nc.NegativeWeight != 0 {
+		for _, sum := range sums {
+			acc.Add(sum)
+		}
+		total = acc.Sum()
+	}
+
+	centroids := make([]*SparseVectorUint32, classes)
+	for c, m := range members {
+		if len(m) == 0 {
+			centroids[c] = &SparseVectorUint32{}
+			continue
+		}
+		acc.AddScaled(sums[c], 1/Value(len(m)))
+		if others := len(vectors) - len(m); total != nil && others > 0 {
+			// The other classes' sum is the total less this class's sum
+			w := nc.NegativeWeight / Value(others)
+			acc.AddScaled(sums[c], w)
+			acc.AddScaled(total, -w)
+		}
+		centroid := acc.Sum()
+		if total != nil {
+			dropNegative(centroid)
+		}
+		centroid.dropZeros()
+		centroids[c] = prune(centroid, nc.MaxCentroidSize)
+		normalize(centroids[c])
+	}
+	nc.centroids = centroids
+	return nil
+}
+
+// dropNegative removes entries whose value is negative
+func dropNegative(sv *SparseVectorUint32) {
+	for i, v := range sv.values {
+		if v < 0 {
+			sv.values[i] = 0
+		}
+	}
+	sv.dropZeros()
+}
+
+// Classes returns the number of classes
+func (nc *NearestCentroid) Classes() int { return len(nc.centroids) }
+
+// Centroids returns the centroid of each class. They have magnitude 1, or
+// are empty, and must not be modified.
+func (nc *NearestCentroid) Centroids() []*SparseVectorUint32 { return nc.centroids }
+
+// Scores returns the cosine of sv with the centroid of each class
+func (nc *NearestCentroid) Scores(sv *SparseVectorUint32) []Value {
+	scores := make([]Value, len(nc.centroids))
+	for c, centroid := range nc.centroids {
+		scores[c] = cosUnit(sv, centroid)
+	}
+	return scores
+}
+
+// Classify returns the class whose centroid has the highest cosine with sv,
+// and the cosine. Ties go to the lowest numbered class. It must not be called
+// before Fit.
+func (nc *NearestCentroid) Classify(sv *SparseVectorUint32) (int, Value) {
+	return nearestCentroid(nc.centroids, sv)
+}
+
+// MarshalBinary encodes the fitted classifier and its settings.
+//
+// The encoding is a version byte, then NegativeWeight as a little-endian
+// float32, then MaxCentroidSize and the number of classes as uvarints, then
+// the centroids.
+func (nc *NearestCentroid) MarshalBinary() ([]byte, error) {
+	buf := []byte{binaryVersion}
+	buf = appendValues(buf, []Value{nc.NegativeWeight})
+	buf = binary.AppendUvarint(buf, uint64(nc.MaxCentroidSize))
+	buf = binary.AppendUvarint(buf, uint64(len(nc.centroids)))
+	for _, centroid := range nc.centroids {
+		var err error
+		if buf, err = appendEmbeddedVector(buf, centroid); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a classifier encoded with MarshalBinary. It
+// replaces the contents of nc.
+func (nc *NearestCentroid) UnmarshalBinary(data []byte) error {
+	if len(data) < 5 {
+		return errors.New("sparsevector: encoded NearestCentroid too short")
+	}
+	if data[0] != binaryVersion {
+		return fmt.Errorf("sparsevector: unsupported encoding version %d", data[0])
+	}
+	weight, err := readValues(data[1:5], 1)
+	if err != nil {
+		return err
+	}
+	data = data[5:]
+
+	maxSize, data, err := readUvarintInt(data)
+	if err != nil {
+		return err
+	}
+	classes, data, err := readUvarintInt(data)
+	if err != nil {
+		return err
+	}
+	// Each centroid takes at least one byte
+	if classes > len(data) {
+		return errors.New("sparsevector: bad NearestCentroid class count")
+	}
+
+	nnc := &NearestCentroid{
+		NegativeWeight:  weight[0],
+		MaxCentroidSize: maxSize,
+		centroids:       make([]*SparseVectorUint32, 0, classes),
+	}
+	for c := 0; c < classes; c++ {
+		var centroid *SparseVectorUint32
+		if centroid, data, err = readEmbeddedVector(data); err != nil {
+			return err
+		}
+		nnc.centroids = append(nnc.centroids, centroid)
+	}
+	if len(data) != 0 {
+		return fmt.Errorf("sparsevector: %d unexpected bytes after NearestCentroid", len(data))
+	}
+	*nc = *nnc
+	return nil
+}
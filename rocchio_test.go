@@ -0,0 +1,212 @@
+package sparsevector
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func BenchmarkNearestCentroidFit(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	vectors, topics := genClusterTestVectors(rnd, 10, 1000)
+	nc := NewNearestCentroid()
+	nc.NegativeWeight = 0.5
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := nc.Fit(vectors, topics); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestNearestCentroid(t *testing.T) {
+	vectors := []*SparseVectorUint32{
+		NewSparseVectorUint32([]uint32{1, 2}, []Value{1, 1}),
+		NewSparseVectorUint32([]uint32{1}, []Value{2}),
+		NewSparseVectorUint32([]uint32{2, 3}, []Value{3, 3}),
+	}
+	labels := []int{0, 0, 2}
+	r := Value(1 / math.Sqrt2)
+
+	tests := []struct {
+		negativeWeight Value
+		maxSize        int
+		// exp are the expected centroids before normalizing
+		exp []*SparseVectorUint32
+	}{
+		{
+			exp: []*SparseVectorUint32{
+				NewSparseVectorUint32([]uint32{1, 2}, []Value{(r + 1) / 2, r / 2}),
+				NewSparseVectorUint32([]uint32{}, []Value{}),
+				NewSparseVectorUint32([]uint32{2, 3}, []Value{r, r}),
+			},
+		},
+		{
+			negativeWeight: 1,
+			exp: []*SparseVectorUint32{
+				NewSparseVectorUint32([]uint32{1}, []Value{1}),
+				NewSparseVectorUint32([]uint32{}, []Value{}),
+				NewSparseVectorUint32([]uint32{2, 3}, []Value{r / 2, r}),
+			},
+		},
+		{
+			negativeWeight: 0.5,
+			exp: []*SparseVectorUint32{
+				NewSparseVectorUint32([]uint32{1, 2}, []Value{(r + 1) / 2, r/2 - r/2}),
+				NewSparseVectorUint32([]uint32{}, []Value{}),
+				NewSparseVectorUint32([]uint32{2, 3}, []Value{r - r/4, r}),
+			},
+		},
+		{
+			// Ties are pruned to the lowest index
+			maxSize: 1,
+			exp: []*SparseVectorUint32{
+				NewSparseVectorUint32([]uint32{1}, []Value{1}),
+				NewSparseVectorUint32([]uint32{}, []Value{}),
+				NewSparseVectorUint32([]uint32{2}, []Value{1}),
+			},
+		},
+	}
+
+	for i, test := range tests {
+		nc := NewNearestCentroid()
+		nc.NegativeWeight = test.negativeWeight
+		nc.MaxCentroidSize = test.maxSize
+		if err := nc.Fit(vectors, labels); err != nil {
+			t.Fatal(err)
+		}
+		if nc.Classes() != 3 {
+			t.Fatalf("Test %d. Have %d classes", i, nc.Classes())
+		}
+		for c, centroid := range nc.Centroids() {
+			exp := test.exp[c]
+			exp.dropZeros()
+			normalize(exp)
+			if len(centroid.indices) == 0 && len(exp.indices) == 0 {
+				continue
+			}
+			if !reflect.DeepEqual(centroid.indices, exp.indices) {
+				t.Errorf("Test %d. Centroid %d not as expected. Have %v", i, c, centroid.indices)
+				continue
+			}
+			for j, v := range centroid.values {
+				if math.Abs(float64(v-exp.values[j])) > 1e-6 {
+					t.Errorf("Test %d. Centroid %d not as expected. Have %v, expected %v", i, c, centroid.values, exp.values)
+					break
+				}
+			}
+		}
+
+		class, cos := nc.Classify(NewSparseVectorUint32([]uint32{2, 3}, []Value{1, 5}))
+		if class != 2 || cos <= 0 {
+			t.Errorf("Test %d. Classified as %d with %f", i, class, cos)
+		}
+		scores := nc.Scores(NewSparseVectorUint32([]uint32{1}, []Value{2}))
+		if len(scores) != 3 || scores[0] <= scores[2] || scores[1] != 0 {
+			t.Errorf("Test %d. Scores not as expected. Have %v", i, scores)
+		}
+	}
+}
+
+func TestNearestCentroidClassify(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	vectors, topics := genClusterTestVectors(rnd, 4, 100)
+
+	for _, negativeWeight := range []Value{0, 0.25, 1} {
+		nc := NewNearestCentroid()
+		nc.NegativeWeight = negativeWeight
+		nc.MaxCentroidSize = 50
+		if err := nc.Fit(vectors[:300], topics[:300]); err != nil {
+			t.Fatal(err)
+		}
+		for c, centroid := range nc.Centroids() {
+			if len(centroid.indices) > 50 {
+				t.Errorf("Weight %f. Centroid %d has %d entries", negativeWeight, c, len(centroid.indices))
+			}
+			if math.Abs(float64(centroid.Mag())-1) > 1e-6 {
+				t.Errorf("Weight %f. Centroid %d has magnitude %f", negativeWeight, c, centroid.Mag())
+			}
+		}
+		for i, sv := range vectors[300:] {
+			if class, _ := nc.Classify(sv); class != topics[300+i] {
+				t.Errorf("Weight %f. Vector %d classified as %d, expected %d", negativeWeight, i, class, topics[300+i])
+			}
+		}
+	}
+}
+
+func TestNearestCentroidFitErrors(t *testing.T) {
+	sv := NewSparseVectorUint32([]uint32{1}, []Value{1})
+	tests := []struct {
+		vectors []*SparseVectorUint32
+		labels  []int
+	}{
+		{},
+		{vectors: []*SparseVectorUint32{sv}},
+		{vectors: []*SparseVectorUint32{sv}, labels: []int{-1}},
+	}
+	for i, test := range tests {
+		if err := NewNearestCentroid().Fit(test.vectors, test.labels); err == nil {
+			t.Errorf("Test %d. Expected an error", i)
+		}
+	}
+}
+
+func TestNearestCentroidBinary(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	vectors, topics := genClusterTestVectors(rnd, 3, 20)
+	// Leave a class with no vectors
+	for i := range topics {
+		topics[i] *= 2
+	}
+	nc := NewNearestCentroid()
+	nc.NegativeWeight = 0.5
+	nc.MaxCentroidSize = 30
+	if err := nc.Fit(vectors, topics); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := nc.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded NearestCentroid
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.NegativeWeight != 0.5 || loaded.MaxCentroidSize != 30 || loaded.Classes() != 5 {
+		t.Fatalf("settings not as expected. Have %v %v %v", loaded.NegativeWeight, loaded.MaxCentroidSize, loaded.Classes())
+	}
+	for c, centroid := range loaded.Centroids() {
+		exp := nc.Centroids()[c]
+		if len(centroid.indices) == 0 && len(exp.indices) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(centroid.indices, exp.indices) || !reflect.DeepEqual(centroid.values, exp.values) {
+			t.Errorf("centroid %d not as expected", c)
+		}
+	}
+	for i, sv := range vectors {
+		class, cos := loaded.Classify(sv)
+		expClass, expCos := nc.Classify(sv)
+		if class != expClass || cos != expCos {
+			t.Errorf("Vector %d classified as %d with %f, expected %d with %f", i, class, cos, expClass, expCos)
+		}
+	}
+
+	for l := 0; l < len(data); l++ {
+		if err := loaded.UnmarshalBinary(data[:l]); err == nil {
+			t.Errorf("expected error decoding %d bytes", l)
+		}
+	}
+	if err := loaded.UnmarshalBinary(append(data, 0)); err == nil {
+		t.Errorf("expected error with trailing data")
+	}
+	bad := append([]byte{}, data...)
+	bad[0] = 99
+	if err := loaded.UnmarshalBinary(bad); err == nil {
+		t.Errorf("expected error with bad version")
+	}
+}
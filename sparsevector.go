@@ -25,10 +25,11 @@ import (
 // values, and stores them in parallel arrays of indices and values. When the
 // vector is created the arrays are sorted by index.
 type SparseVectorUint32 struct {
-	indices  []uint32
-	values   []Value
-	mag      Value
-	magClean bool
+	indices   []uint32
+	values    []Value
+	mag       Value
+	magClean  bool
+	precision Precision
 }
 
 // NewSparseVector creates a new sparse vector. Pass in parallel arrays of the
@@ -42,6 +43,7 @@ func NewSparseVectorUint32(indices []uint32, values []Value) *SparseVectorUint32
 	}
 	svs := sparseVectorUint32Sort{sv}
 	sort.Sort(svs)
+	debugCheck(sv)
 	return sv
 }
 
@@ -63,37 +65,30 @@ func (sv sparseVectorUint32Sort) Swap(i, j int) {
 func (v *SparseVectorUint32) Mag() Value {
 	if !v.magClean {
 		// Could use v1.Dot(v2), but this is more efficient
-		var magsq Value
-		for _, val := range v.values {
-			magsq += val * val
-		}
-		v.mag = Value(math.Sqrt(float64(magsq)))
-
+		v.mag = magPrecision(v.values, v.precision)
 		v.magClean = true
 	}
 	return v.mag
 }
 
 // Dot calculates the dot product of this vector and another sparse vector.
+// The other vector may also be a CompressedSparseVector, a BitmapVector, or
+// one of the quantized vectors.
 func (sv1 *SparseVectorUint32) Dot(sv2in Vector) Value {
-	sv2 := sv2in.(*SparseVectorUint32)
-
-	var i1, i2 int
-	var dp Value
-	sv1l := len(sv1.indices)
-	sv2l := len(sv2.indices)
-	for i1 < sv1l && i2 < sv2l {
-		if sv1.indices[i1] < sv2.indices[i2] {
-			i1 += 1
-		} else if sv2.indices[i2] < sv1.indices[i1] {
-			i2 += 1
-		} else {
-			dp += sv1.values[i1] * sv2.values[i2]
-			i1 += 1
-			i2 += 1
-		}
+	switch v := sv2in.(type) {
+	case *CompressedSparseVector:
+		return v.Dot(sv1)
+	case *BitmapVector:
+		return v.Dot(sv1)
+	case *Int8SparseVector:
+		return v.Dot(sv1)
+	case *Float16SparseVector:
+		return v.Dot(sv1)
+	case *BFloat16SparseVector:
+		return v.Dot(sv1)
 	}
-	return dp
+	sv2 := sv2in.(*SparseVectorUint32)
+	return sv1.DotPrecision(sv2, maxPrecision(sv1.precision, sv2.precision))
 }
 
 func (sv1 *SparseVectorUint32) Add(sv2 Vector) Vector {
@@ -169,11 +164,7 @@ func (sv1 *SparseVectorUint32) Cos(sv2 Vector) Value {
 
 // Mean() Calculates the mean element value (mean of values that are present)
 func (sv *SparseVectorUint32) Mean() Value {
-	var total Value
-	for _, v := range sv.values {
-		total += v
-	}
-	return total / Value(len(sv.values))
+	return meanPrecision(sv.values, sv.precision)
 }
 
 // AddConst adds a constant value to each of the present values in the sparse
@@ -183,6 +174,7 @@ func (sv *SparseVectorUint32) AddConst(toAdd Value) {
 		sv.values[i] = v + toAdd
 	}
 	sv.magClean = false
+	debugCheck(sv)
 }
 
 // SubConst subtracts a constant value to each of the present values in the sparse
@@ -196,6 +188,8 @@ func (sv *SparseVectorUint32) Mult(l Value) {
 	for i, v := range sv.values {
 		sv.values[i] = l * v
 	}
+	sv.magClean = false
+	debugCheck(sv)
 }
 
 // Iter lets you iterate over the members of the sparse vector
@@ -213,6 +207,7 @@ func (sv *SparseVectorUint32) IterUpdate(f func(index uint32, value Value) Value
 		sv.values[i] = f(index, value)
 	}
 	sv.magClean = false
+	debugCheck(sv)
 }
 
 // GetIndices returns the array of indices of non-zero values in the vector
@@ -225,6 +220,43 @@ func (sv *SparseVectorUint32) MapIndices(im map[uint32]uint32) {
 	for i, idx := range sv.indices {
 		sv.indices[i] = im[idx]
 	}
+	debugCheck(sv)
+}
+
+// mergeDuplicates adds together the values of entries with the same index.
+// The vector must be sorted.
+func (sv *SparseVectorUint32) mergeDuplicates() {
+	if len(sv.indices) == 0 {
+		return
+	}
+	out := 0
+	for i := 1; i < len(sv.indices); i++ {
+		if sv.indices[i] == sv.indices[out] {
+			sv.values[out] += sv.values[i]
+		} else {
+			out++
+			sv.indices[out] = sv.indices[i]
+			sv.values[out] = sv.values[i]
+		}
+	}
+	sv.indices = sv.indices[:out+1]
+	sv.values = sv.values[:out+1]
+	sv.magClean = false
+}
+
+// dropZeros removes entries whose value is zero
+func (sv *SparseVectorUint32) dropZeros() {
+	out := 0
+	for i, v := range sv.values {
+		if v != 0 {
+			sv.indices[out] = sv.indices[i]
+			sv.values[out] = v
+			out++
+		}
+	}
+	sv.indices = sv.indices[:out]
+	sv.values = sv.values[:out]
+	sv.magClean = false
 }
 
 var _ SparseVector = (*SparseVectorUint32)(nil)
@@ -129,3 +129,15 @@ func TestMultSparseVectorUint32(t *testing.T) {
 		}
 	}
 }
+
+func TestMultSparseVectorUint32Mag(t *testing.T) {
+	// The magnitude is cached, so Mult must clear it
+	sv := NewSparseVectorUint32([]uint32{1, 3}, []Value{3, 4})
+	if mag := sv.Mag(); mag != 5 {
+		t.Fatalf("Mag is %f", mag)
+	}
+	sv.Mult(2)
+	if mag := sv.Mag(); mag != 10 {
+		t.Errorf("Mag after Mult is %f", mag)
+	}
+}
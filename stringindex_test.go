@@ -37,10 +37,11 @@ func ExampleStringIndex() {
 		4.5,
 		3.0,
 		5.0,
+		4.0,
 	})
 
 	// Naive similarity measure between these two films
 	cos := starwars.Cos(battlestargalactica)
 	fmt.Printf("Similarity is %f", cos)
-	// Output: Similarity is 0.928748
+	// Output: Similarity is 0.843647
 }
@@ -0,0 +1,148 @@
+package sparsevector
+
+import (
+	"strings"
+	"unicode"
+)
+
+// EnglishStopWords is a short list of common English words that carry little
+// meaning, for use as TextVectorizer.StopWords
+var EnglishStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "from": true, "has": true,
+	"have": true, "he": true, "her": true, "his": true, "i": true, "if": true,
+	"in": true, "is": true, "it": true, "its": true, "not": true, "of": true,
+	"on": true, "or": true, "she": true, "so": true, "that": true, "the": true,
+	"their": true, "them": true, "they": true, "this": true, "to": true,
+	"was": true, "we": true, "were": true, "which": true, "will": true,
+	"with": true, "you": true,
+}
+
+// ShinglePrefix starts every character shingle feature. It can't occur in a
+// word n-gram, so shingles and n-grams with the same text are counted
+// separately.
+const ShinglePrefix = "#"
+
+// TextVectorizer turns text into count vectors. Text is split into words at
+// anything that isn't a letter or a digit. The features counted are word
+// n-grams, character shingles or both.
+//
+// The zero value counts nothing, so create one with NewTextVectorizer, which
+// lowercases the text and counts single words.
+type TextVectorizer struct {
+	// Lowercase converts the text to lower case first. NewTextVectorizer sets
+	// it to true.
+	Lowercase bool
+	// StopWords are words that are dropped before building n-grams. Stop
+	// words are matched after lowercasing.
+	StopWords map[string]bool
+	// MinN and MaxN set the sizes of word n-grams counted. n-grams are the
+	// words joined by single spaces. If MaxN is zero no words are counted.
+	// NewTextVectorizer sets both to 1, counting single words.
+	MinN, MaxN int
+	// ShingleSize is the number of characters in each character shingle. The
+	// shingles are taken from the words joined by single spaces, before stop
+	// words are removed, and are prefixed with ShinglePrefix. If it is zero
+	// no shingles are counted, which is the default.
+	ShingleSize int
+}
+
+// NewTextVectorizer creates a TextVectorizer that lowercases the text and
+// counts single words
+func NewTextVectorizer() *TextVectorizer {
+	return &TextVectorizer{
+		Lowercase: true,
+		MinN:      1,
+		MaxN:      1,
+	}
+}
+
+// words splits the text into words
+func (tv *TextVectorizer) words(text string) []string {
+	if tv.Lowercase {
+		text = strings.ToLower(text)
+	}
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Tokens returns the words of the text, with stop words removed
+func (tv *TextVectorizer) Tokens(text string) []string {
+	return tv.removeStopWords(tv.words(text))
+}
+
+func (tv *TextVectorizer) removeStopWords(words []string) []string {
+	if len(tv.StopWords) == 0 {
+		return words
+	}
+	tokens := words[:0:0]
+	for _, word := range words {
+		if !tv.StopWords[word] {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+// Features returns each feature of the text in turn. Features repeat as often
+// as they occur.
+func (tv *TextVectorizer) Features(text string) []string {
+	words := tv.words(text)
+	var features []string
+
+	tokens := tv.removeStopWords(words)
+	minN := tv.MinN
+	if minN < 1 {
+		minN = 1
+	}
+	for n := minN; n <= tv.MaxN; n++ {
+		for i := 0; i+n <= len(tokens); i++ {
+			features = append(features, strings.Join(tokens[i:i+n], " "))
+		}
+	}
+
+	if tv.ShingleSize > 0 {
+		chars := []rune(strings.Join(words, " "))
+		for i := 0; i+tv.ShingleSize <= len(chars); i++ {
+			features = append(features, ShinglePrefix+string(chars[i:i+tv.ShingleSize]))
+		}
+	}
+	return features
+}
+
+// Vectorize counts the features of the text, returning a GenSparseVector
+// indexed by the features
+func (tv *TextVectorizer) Vectorize(text string) *GenSparseVector {
+	counts := make(map[string]Value)
+	for _, feature := range tv.Features(text) {
+		counts[feature]++
+	}
+	index := make(StringIndex, 0, len(counts))
+	values := make([]Value, 0, len(counts))
+	for feature, count := range counts {
+		index = append(index, feature)
+		values = append(values, count)
+	}
+	return NewGenSparseVector(index, values)
+}
+
+// VectorizeVocabulary counts the features of the text, returning a
+// SparseVectorUint32 indexed by the ids of the features in vocab. Features not
+// in vocab are dropped. To build the vocabulary, add each document's vector
+// from Vectorize with Vocabulary.AddVector.
+func (tv *TextVectorizer) VectorizeVocabulary(text string, vocab *Vocabulary) *SparseVectorUint32 {
+	counts := make(map[uint32]Value)
+	for _, feature := range tv.Features(text) {
+		if id, ok := vocab.ID(feature); ok {
+			counts[id]++
+		}
+	}
+	indices := make([]uint32, 0, len(counts))
+	values := make([]Value, 0, len(counts))
+	for id, count := range counts {
+		indices = append(indices, id)
+		values = append(values, count)
+	}
+	return NewSparseVectorUint32(indices, values)
+}
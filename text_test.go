@@ -0,0 +1,80 @@
+package sparsevector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTextVectorizerFeatures(t *testing.T) {
+	text := "The cat sat on the Mat, the cat's mat!"
+	tests := []struct {
+		setup func(tv *TextVectorizer)
+		exp   []string
+	}{
+		{func(tv *TextVectorizer) {}, []string{"the", "cat", "sat", "on", "the", "mat", "the", "cat", "s", "mat"}},
+		{func(tv *TextVectorizer) { tv.Lowercase = false }, []string{"The", "cat", "sat", "on", "the", "Mat", "the", "cat", "s", "mat"}},
+		{func(tv *TextVectorizer) { tv.StopWords = EnglishStopWords }, []string{"cat", "sat", "mat", "cat", "s", "mat"}},
+		{
+			func(tv *TextVectorizer) {
+				tv.StopWords = EnglishStopWords
+				tv.MaxN = 2
+			},
+			[]string{"cat", "sat", "mat", "cat", "s", "mat", "cat sat", "sat mat", "mat cat", "cat s", "s mat"},
+		},
+		{
+			func(tv *TextVectorizer) {
+				tv.StopWords = EnglishStopWords
+				tv.MinN = 2
+				tv.MaxN = 3
+			},
+			[]string{"cat sat", "sat mat", "mat cat", "cat s", "s mat", "cat sat mat", "sat mat cat", "mat cat s", "cat s mat"},
+		},
+	}
+
+	for i, test := range tests {
+		tv := NewTextVectorizer()
+		test.setup(tv)
+		if features := tv.Features(text); !reflect.DeepEqual(test.exp, features) {
+			t.Errorf("Test %d. Features not as expected. Have %q", i, features)
+		}
+	}
+
+	// Shingles only
+	tv := NewTextVectorizer()
+	tv.MaxN = 0
+	tv.ShingleSize = 3
+	if features := tv.Features("Ça va, ça?"); !reflect.DeepEqual([]string{"#ça ", "#a v", "# va", "#va ", "#a ç", "# ça"}, features) {
+		t.Errorf("Shingles not as expected. Have %q", features)
+	}
+	if features := tv.Features("ab"); len(features) != 0 {
+		t.Errorf("Short text has shingles %q", features)
+	}
+}
+
+func TestTextVectorizerVectorize(t *testing.T) {
+	tv := NewTextVectorizer()
+	tv.StopWords = EnglishStopWords
+
+	gsv := tv.Vectorize("The cat sat on the mat, the cat's mat")
+	exp := NewGenSparseVector(StringIndex{"cat", "mat", "s", "sat"}, []Value{2, 2, 1, 1})
+	if !reflect.DeepEqual(exp, gsv) {
+		t.Errorf("Vector not as expected. Have %v", gsv)
+	}
+
+	// Words and shingles with the same text are counted separately
+	tv = NewTextVectorizer()
+	tv.ShingleSize = 3
+	exp = NewGenSparseVector(StringIndex{"#cat", "cat"}, []Value{1, 1})
+	if gsv := tv.Vectorize("cat"); !reflect.DeepEqual(exp, gsv) {
+		t.Errorf("Vector with shingles not as expected. Have %v", gsv)
+	}
+
+	vocab := NewVocabulary()
+	vocab.AddVector(gsv)
+	sv := tv.VectorizeVocabulary("A mat, a dog and a cat and a mat", vocab)
+	catID, _ := vocab.ID("cat")
+	matID, _ := vocab.ID("mat")
+	if !reflect.DeepEqual(NewSparseVectorUint32([]uint32{catID, matID}, []Value{1, 2}), sv) {
+		t.Errorf("Vocabulary vector not as expected. Have %v", sv)
+	}
+}
@@ -0,0 +1,262 @@
+package sparsevector
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// TfScaling says how a TfIdf scales the raw counts in a vector
+type TfScaling byte
+
+const (
+	// TfRaw uses the counts as they are
+	TfRaw TfScaling = iota
+	// TfLog replaces each count c with 1 + ln(c)
+	TfLog
+	// TfBoolean replaces each count with 1
+	TfBoolean
+)
+
+// TfIdf weights count vectors by term frequency times inverse document
+// frequency. It is first fitted on a corpus of count vectors, one per
+// document, to find how many documents contain each index. It can then
+// transform count vectors into tf-idf weighted vectors.
+//
+// The corpus can be of SparseVectorUint32 or GenSparseVector, and the vectors
+// transformed should be of the same type. Indices that did not occur in the
+// corpus are dropped by the transform.
+//
+// The zero value is ready to use, with raw counts and neither smoothing nor
+// normalization. NewTfIdf turns on smoothing and normalization.
+type TfIdf struct {
+	// TF is how counts are scaled. The default is TfRaw.
+	TF TfScaling
+	// SmoothIdf adds one to the document count and to every document
+	// frequency, as if there were an extra document containing every index.
+	// NewTfIdf sets it to true.
+	SmoothIdf bool
+	// Normalize scales transformed vectors to have magnitude 1. NewTfIdf sets
+	// it to true.
+	Normalize bool
+
+	docs int
+	df   map[uint32]int
+	// Document frequencies of GenSparseVector indices are counted by a
+	// vocabulary
+	vocab *Vocabulary
+}
+
+// NewTfIdf creates an unfitted TfIdf with raw counts, smoothing and
+// normalization.
+func NewTfIdf() *TfIdf {
+	return &TfIdf{
+		SmoothIdf: true,
+		Normalize: true,
+		df:        make(map[uint32]int),
+		vocab:     NewVocabulary(),
+	}
+}
+
+// Fit adds a document to the corpus. Call it for each count vector in the
+// corpus. v must be a *SparseVectorUint32 or a *GenSparseVector.
+func (t *TfIdf) Fit(v Vector) {
+	switch v := v.(type) {
+	case *SparseVectorUint32:
+		if t.df == nil {
+			t.df = make(map[uint32]int)
+		}
+		for _, index := range v.indices {
+			t.df[index]++
+		}
+	case *GenSparseVector:
+		if t.vocab == nil {
+			t.vocab = NewVocabulary()
+		}
+		t.vocab.AddVector(v)
+	default:
+		panic(fmt.Sprintf("sparsevector: TfIdf cannot fit %T", v))
+	}
+	t.docs++
+}
+
+// Docs returns the number of documents the TfIdf has been fitted on
+func (t *TfIdf) Docs() int { return t.docs }
+
+// vocabulary returns the vocabulary counting GenSparseVector indices. A TfIdf
+// that hasn't been fitted on a GenSparseVector may not have one yet.
+func (t *TfIdf) vocabulary() *Vocabulary {
+	if t.vocab == nil {
+		return NewVocabulary()
+	}
+	return t.vocab
+}
+
+// idf calculates the inverse document frequency for an index that occurs in
+// df documents
+func (t *TfIdf) idf(df int) Value {
+	if t.SmoothIdf {
+		return Value(math.Log(float64(1+t.docs)/float64(1+df)) + 1)
+	}
+	return Value(math.Log(float64(t.docs)/float64(df)) + 1)
+}
+
+// tf scales a count. It returns false if the entry should be dropped.
+func (t *TfIdf) tf(count Value) (Value, bool) {
+	switch t.TF {
+	case TfLog:
+		if count <= 0 {
+			return 0, false
+		}
+		return 1 + Value(math.Log(float64(count))), true
+	case TfBoolean:
+		return 1, count != 0
+	}
+	return count, true
+}
+
+// Transform creates a tf-idf weighted copy of a count vector
+func (t *TfIdf) Transform(sv *SparseVectorUint32) *SparseVectorUint32 {
+	out := &SparseVectorUint32{
+		indices: make([]uint32, 0, len(sv.indices)),
+		values:  make([]Value, 0, len(sv.indices)),
+	}
+	for i, index := range sv.indices {
+		df := t.df[index]
+		tf, ok := t.tf(sv.values[i])
+		if df == 0 || !ok {
+			continue
+		}
+		out.indices = append(out.indices, index)
+		out.values = append(out.values, tf*t.idf(df))
+	}
+	if t.Normalize {
+		normalize(out)
+	}
+	return out
+}
+
+// TransformGen creates a tf-idf weighted copy of a GenSparseVector of counts
+func (t *TfIdf) TransformGen(gsv *GenSparseVector) *GenSparseVector {
+	vocab := t.vocabulary()
+	index := gsv.index.New(len(gsv.values))
+	values := make([]Value, 0, len(gsv.values))
+	for i, count := range gsv.values {
+		key := gsv.index.GetAtLocation(i)
+		id, ok := vocab.ID(key)
+		if !ok {
+			continue
+		}
+		tf, ok := t.tf(count)
+		if !ok {
+			continue
+		}
+		index = index.Append(key)
+		values = append(values, tf*t.idf(vocab.Count(id)))
+	}
+	out := &GenSparseVector{index: index, values: values}
+	if t.Normalize {
+		normalize(out)
+	}
+	return out
+}
+
+// normalize scales a vector to magnitude 1, unless it is all zeros
+func normalize(v Vector) {
+	if mag := v.Mag(); mag != 0 {
+		v.Mult(1 / mag)
+	}
+}
+
+// MarshalBinary encodes the fitted TfIdf and its settings.
+//
+// The encoding is a version byte, the tf scaling, a byte of flags and the
+// number of documents, then the uint32 document frequencies as delta-encoded
+// index and count uvarint pairs, then the vocabulary counting GenSparseVector
+// indices.
+func (t *TfIdf) MarshalBinary() ([]byte, error) {
+	var flags byte
+	if t.SmoothIdf {
+		flags |= 1
+	}
+	if t.Normalize {
+		flags |= 2
+	}
+	buf := []byte{binaryVersion, byte(t.TF), flags}
+	buf = binary.AppendUvarint(buf, uint64(t.docs))
+
+	indices := make([]uint32, 0, len(t.df))
+	for index := range t.df {
+		indices = append(indices, index)
+	}
+	sort.Sort(Uint32Index(indices))
+	buf = binary.AppendUvarint(buf, uint64(len(indices)))
+	var prev uint32
+	for _, index := range indices {
+		buf = binary.AppendUvarint(buf, uint64(index-prev))
+		buf = binary.AppendUvarint(buf, uint64(t.df[index]))
+		prev = index
+	}
+
+	vocab, err := t.vocabulary().MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, vocab...), nil
+}
+
+// UnmarshalBinary decodes a TfIdf encoded with MarshalBinary. It replaces the
+// contents of t.
+func (t *TfIdf) UnmarshalBinary(data []byte) error {
+	if len(data) < 3 {
+		return errors.New("sparsevector: encoded TfIdf too short")
+	}
+	if data[0] != binaryVersion {
+		return fmt.Errorf("sparsevector: unsupported encoding version %d", data[0])
+	}
+	nt := NewTfIdf()
+	nt.TF = TfScaling(data[1])
+	if nt.TF > TfBoolean {
+		return fmt.Errorf("sparsevector: unknown tf scaling %d", nt.TF)
+	}
+	nt.SmoothIdf = data[2]&1 != 0
+	nt.Normalize = data[2]&2 != 0
+	data = data[3:]
+
+	docs, n := binary.Uvarint(data)
+	if n <= 0 || docs > math.MaxInt32 {
+		return errors.New("sparsevector: bad TfIdf document count")
+	}
+	nt.docs = int(docs)
+	data = data[n:]
+
+	l, n := binary.Uvarint(data)
+	// Each entry takes at least two bytes
+	if n <= 0 || l > uint64(len(data)-n)/2 {
+		return errors.New("sparsevector: bad TfIdf length")
+	}
+	data = data[n:]
+	var index uint64
+	for i := 0; i < int(l); i++ {
+		delta, n := binary.Uvarint(data)
+		if n <= 0 || (i > 0 && delta == 0) || index+delta > math.MaxUint32 {
+			return fmt.Errorf("sparsevector: bad TfIdf index %d", i)
+		}
+		index += delta
+		data = data[n:]
+		df, n := binary.Uvarint(data)
+		if n <= 0 || df == 0 || df > docs {
+			return fmt.Errorf("sparsevector: bad TfIdf document frequency %d", i)
+		}
+		nt.df[uint32(index)] = int(df)
+		data = data[n:]
+	}
+
+	if err := nt.vocab.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	*t = *nt
+	return nil
+}
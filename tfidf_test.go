@@ -0,0 +1,147 @@
+package sparsevector
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestTfIdf(t *testing.T) {
+	corpus := []*SparseVectorUint32{
+		NewSparseVectorUint32([]uint32{1, 2}, []Value{2, 1}),
+		NewSparseVectorUint32([]uint32{1, 3}, []Value{1, 1}),
+		NewSparseVectorUint32([]uint32{1, 2, 4}, []Value{1, 3, 1}),
+	}
+	doc := NewSparseVectorUint32([]uint32{1, 2, 5}, []Value{4, 1, 1})
+
+	// idf with smoothing is ln((1+3)/(1+df)) + 1
+	idf1 := math.Log(4.0/4) + 1
+	idf2 := math.Log(4.0/3) + 1
+	tests := []struct {
+		tf        TfScaling
+		smooth    bool
+		normalize bool
+		exp       []float64
+	}{
+		{TfRaw, true, false, []float64{4 * idf1, idf2}},
+		{TfLog, true, false, []float64{(1 + math.Log(4)) * idf1, idf2}},
+		{TfBoolean, true, false, []float64{idf1, idf2}},
+		{TfRaw, false, false, []float64{4 * (math.Log(3.0/3) + 1), math.Log(3.0/2) + 1}},
+		{TfRaw, true, true, []float64{4 * idf1 / math.Hypot(4*idf1, idf2), idf2 / math.Hypot(4*idf1, idf2)}},
+	}
+
+	for i, test := range tests {
+		tfidf := NewTfIdf()
+		tfidf.TF = test.tf
+		tfidf.SmoothIdf = test.smooth
+		tfidf.Normalize = test.normalize
+		for _, sv := range corpus {
+			tfidf.Fit(sv)
+		}
+		if tfidf.Docs() != 3 {
+			t.Errorf("Test %d. Docs is %d", i, tfidf.Docs())
+		}
+
+		out := tfidf.Transform(doc)
+		if !reflect.DeepEqual([]uint32{1, 2}, out.indices) {
+			t.Errorf("Test %d. Indices not as expected. Have %v", i, out.indices)
+			continue
+		}
+		for j, v := range out.values {
+			if math.Abs(float64(v)-test.exp[j]) > 1e-6 {
+				t.Errorf("Test %d. Value %d is %f, expected %f", i, j, v, test.exp[j])
+			}
+		}
+		if test.normalize && math.Abs(float64(out.Mag())-1) > 1e-6 {
+			t.Errorf("Test %d. Mag is %f", i, out.Mag())
+		}
+
+		// Save and reload
+		data, err := tfidf.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Test %d. Marshal failed. %v", i, err)
+		}
+		var loaded TfIdf
+		if err := loaded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("Test %d. Unmarshal failed. %v", i, err)
+		}
+		if !reflect.DeepEqual(tfidf, &loaded) {
+			t.Errorf("Test %d. Reloaded TfIdf not as expected", i)
+		}
+	}
+}
+
+func TestTfIdfGen(t *testing.T) {
+	tfidf := NewTfIdf()
+	tfidf.Fit(NewGenSparseVector(StringIndex{"a", "b"}, []Value{2, 1}))
+	tfidf.Fit(NewGenSparseVector(StringIndex{"a", "c"}, []Value{1, 1}))
+
+	out := tfidf.TransformGen(NewGenSparseVector(StringIndex{"a", "c", "d"}, []Value{1, 1, 5}))
+	if !reflect.DeepEqual(StringIndex{"a", "c"}, out.index) {
+		t.Fatalf("Index not as expected. Have %v", out.index)
+	}
+	// a is in every document, so has a lower weight than c
+	if out.values[0] >= out.values[1] {
+		t.Errorf("Values not as expected. Have %v", out.values)
+	}
+	if math.Abs(float64(out.Mag())-1) > 1e-6 {
+		t.Errorf("Mag is %f", out.Mag())
+	}
+
+	data, err := tfidf.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Marshal failed. %v", err)
+	}
+	loaded := NewTfIdf()
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("Unmarshal failed. %v", err)
+	}
+	reloaded := loaded.TransformGen(NewGenSparseVector(StringIndex{"a", "c", "d"}, []Value{1, 1, 5}))
+	if !reflect.DeepEqual(out.index, reloaded.index) || !reflect.DeepEqual(out.values, reloaded.values) {
+		t.Errorf("Reloaded TfIdf transforms differently")
+	}
+
+	for i, data := range [][]byte{{}, {1, 0}, {2, 0, 0, 0, 0}, {1, 9, 0, 0, 0}, {1, 0, 0, 1, 1, 0, 1}} {
+		if err := loaded.UnmarshalBinary(data); err == nil {
+			t.Errorf("Test %d. Expected error", i)
+		}
+	}
+}
+
+func TestTfIdfZeroValue(t *testing.T) {
+	// The zero value works, with raw counts and no smoothing or
+	// normalization
+	var tfidf TfIdf
+	if out := tfidf.Transform(NewSparseVectorUint32([]uint32{1}, []Value{1})); len(out.indices) != 0 {
+		t.Errorf("unfitted transform not empty. Have %v", out.indices)
+	}
+	tfidf.Fit(NewSparseVectorUint32([]uint32{1, 2}, []Value{2, 1}))
+	tfidf.Fit(NewSparseVectorUint32([]uint32{1}, []Value{1}))
+
+	out := tfidf.Transform(NewSparseVectorUint32([]uint32{1, 2}, []Value{3, 1}))
+	exp := []Value{3, Value(math.Log(2) + 1)}
+	if !reflect.DeepEqual([]uint32{1, 2}, out.indices) || !reflect.DeepEqual(exp, out.values) {
+		t.Errorf("transform not as expected. Have %v %v", out.indices, out.values)
+	}
+	if gen := tfidf.TransformGen(NewGenSparseVector(StringIndex{"a"}, []Value{1})); len(gen.values) != 0 {
+		t.Errorf("TransformGen of unseen key not empty. Have %v", gen.values)
+	}
+
+	data, err := tfidf.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded TfIdf
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded := loaded.Transform(NewSparseVectorUint32([]uint32{1, 2}, []Value{3, 1})); !reflect.DeepEqual(out.values, reloaded.values) {
+		t.Errorf("reloaded TfIdf transforms differently")
+	}
+
+	var gen TfIdf
+	gen.Fit(NewGenSparseVector(StringIndex{"a", "b"}, []Value{1, 1}))
+	if out := gen.TransformGen(NewGenSparseVector(StringIndex{"a"}, []Value{2})); !reflect.DeepEqual(out.values, []Value{2}) {
+		t.Errorf("TransformGen not as expected. Have %v", out.values)
+	}
+}
@@ -0,0 +1,255 @@
+package sparsevector
+
+import (
+	"fmt"
+	"math"
+)
+
+// ValidationError reports a vector that breaks one of its invariants
+type ValidationError struct {
+	// Type is the type of the vector, such as "SparseVectorUint32"
+	Type string
+	// Position is the position of the offending entry, or -1 if the problem
+	// is with the vector as a whole
+	Position int
+	// Reason describes the problem
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Position < 0 {
+		return fmt.Sprintf("sparsevector: invalid %s: %s", e.Type, e.Reason)
+	}
+	return fmt.Sprintf("sparsevector: invalid %s at position %d: %s", e.Type, e.Position, e.Reason)
+}
+
+// validator is implemented by all the vector types
+type validator interface {
+	Validate() error
+}
+
+// debugCheck panics if v is invalid, when the package is built with the
+// sparsevectordebug tag. Otherwise it does nothing.
+func debugCheck(v validator) {
+	if debugChecks {
+		if err := v.Validate(); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// validateIndices checks that indices are strictly increasing and that there
+// are l of them
+func validateIndices(typ string, indices []uint32, l int) error {
+	if len(indices) != l {
+		return &ValidationError{typ, -1, fmt.Sprintf("%d indices but %d values", len(indices), l)}
+	}
+	for i := 1; i < len(indices); i++ {
+		if indices[i] <= indices[i-1] {
+			return &ValidationError{typ, i, fmt.Sprintf("index %d follows %d", indices[i], indices[i-1])}
+		}
+	}
+	return nil
+}
+
+// validateValues checks that values are finite
+func validateValues(typ string, values []Value) error {
+	for i, v := range values {
+		if err := validateValue(typ, i, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateValue(typ string, i int, v Value) error {
+	if !isFinite(v) {
+		return &ValidationError{typ, i, fmt.Sprintf("value %v is not finite", v)}
+	}
+	return nil
+}
+
+func isFinite(v Value) bool {
+	return !math.IsNaN(float64(v)) && !math.IsInf(float64(v), 0)
+}
+
+// Validate checks that the indices and values are the same length, the
+// indices are strictly increasing and the values are finite.
+func (sv *SparseVectorUint32) Validate() error {
+	if err := validateIndices("SparseVectorUint32", sv.indices, len(sv.values)); err != nil {
+		return err
+	}
+	return validateValues("SparseVectorUint32", sv.values)
+}
+
+// Validate checks that the index and values are the same length, the index
+// is strictly increasing and the values are finite. A vector with no index,
+// such as the zero value, is valid if it has no values.
+func (sv *GenSparseVector) Validate() error {
+	if sv.index == nil {
+		if len(sv.values) != 0 {
+			return &ValidationError{"GenSparseVector", -1, fmt.Sprintf("no index but %d values", len(sv.values))}
+		}
+		return nil
+	}
+	if sv.index.Len() != len(sv.values) {
+		return &ValidationError{"GenSparseVector", -1, fmt.Sprintf("%d indices but %d values", sv.index.Len(), len(sv.values))}
+	}
+	for i := 1; i < sv.index.Len(); i++ {
+		if !sv.index.Less(i-1, i) {
+			return &ValidationError{"GenSparseVector", i, fmt.Sprintf("index %v follows %v", sv.index.GetAtLocation(i), sv.index.GetAtLocation(i-1))}
+		}
+	}
+	return validateValues("GenSparseVector", sv.values)
+}
+
+// Validate checks that the values are finite. As the vector is not ordered,
+// the position reported is always -1.
+func (m *MapSparseVector) Validate() error {
+	for index, v := range m.values {
+		if !isFinite(v) {
+			return &ValidationError{"MapSparseVector", -1, fmt.Sprintf("value %v at index %d is not finite", v, index)}
+		}
+	}
+	return nil
+}
+
+// Validate checks that the blocks match the indices they hold, the indices
+// are strictly increasing and the values are finite.
+func (c *CompressedSparseVector) Validate() error {
+	const typ = "CompressedSparseVector"
+	nvalues := len(c.values)
+	if c.qvalues != nil {
+		nvalues = len(c.qvalues)
+		if !isFinite(c.qmin) || !isFinite(c.qscale) {
+			return &ValidationError{typ, -1, fmt.Sprintf("quantization minimum %v and scale %v must be finite", c.qmin, c.qscale)}
+		}
+	}
+	if nvalues != c.length {
+		return &ValidationError{typ, -1, fmt.Sprintf("length %d but %d values", c.length, nvalues)}
+	}
+	if len(c.blocks) != (c.length+compressedBlockSize-1)/compressedBlockSize {
+		return &ValidationError{typ, -1, fmt.Sprintf("%d blocks for %d entries", len(c.blocks), c.length)}
+	}
+
+	var buf [compressedBlockSize]uint32
+	var prev uint32
+	for b := range c.blocks {
+		base := b * compressedBlockSize
+		indices := c.unpackBlock(b, &buf)
+		if indices[len(indices)-1] != c.blocks[b].last {
+			return &ValidationError{typ, base + len(indices) - 1, fmt.Sprintf("block %d ends at %d, not %d", b, indices[len(indices)-1], c.blocks[b].last)}
+		}
+		for i, index := range indices {
+			if (base > 0 || i > 0) && index <= prev {
+				return &ValidationError{typ, base + i, fmt.Sprintf("index %d follows %d", index, prev)}
+			}
+			prev = index
+			if err := validateValue(typ, base+i, c.value(base+i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks that the containers are in order and hold sorted, distinct
+// values, that the cardinality is right and that the weight is finite.
+func (b *BitmapVector) Validate() error {
+	const typ = "BitmapVector"
+	if len(b.keys) != len(b.containers) {
+		return &ValidationError{typ, -1, fmt.Sprintf("%d keys but %d containers", len(b.keys), len(b.containers))}
+	}
+	if !isFinite(b.weight) {
+		return &ValidationError{typ, -1, fmt.Sprintf("weight %v is not finite", b.weight)}
+	}
+
+	// Positions are counted across all the containers
+	var card int
+	for i, key := range b.keys {
+		if i > 0 && key <= b.keys[i-1] {
+			return &ValidationError{typ, card, fmt.Sprintf("container key %d follows %d", key, b.keys[i-1])}
+		}
+		switch c := b.containers[i].(type) {
+		case arrayContainer:
+			for j := 1; j < len(c); j++ {
+				if c[j] <= c[j-1] {
+					return &ValidationError{typ, card + j, fmt.Sprintf("index %d follows %d", uint32(key)<<16|uint32(c[j]), uint32(key)<<16|uint32(c[j-1]))}
+				}
+			}
+		case bitsetContainer:
+			if len(c) != 1024 {
+				return &ValidationError{typ, card, fmt.Sprintf("bitmap container has %d words", len(c))}
+			}
+		case runContainer:
+			for j, run := range c {
+				if int(run.start)+int(run.length) > math.MaxUint16 {
+					return &ValidationError{typ, card, fmt.Sprintf("run %d overflows", j)}
+				}
+				if j > 0 && int(run.start) <= int(c[j-1].start)+int(c[j-1].length)+1 {
+					return &ValidationError{typ, card, fmt.Sprintf("run %d overlaps or touches the previous run", j)}
+				}
+			}
+		}
+		n := b.containers[i].cardinality()
+		if n == 0 {
+			return &ValidationError{typ, card, fmt.Sprintf("container for key %d is empty", key)}
+		}
+		card += n
+	}
+	if card != b.card {
+		return &ValidationError{typ, -1, fmt.Sprintf("cardinality is %d, but containers hold %d", b.card, card)}
+	}
+	return nil
+}
+
+// Validate checks that the indices and values are the same length, the
+// indices are strictly increasing and the scale is finite.
+func (q *Int8SparseVector) Validate() error {
+	if err := validateIndices("Int8SparseVector", q.indices, len(q.values)); err != nil {
+		return err
+	}
+	if !isFinite(q.scale) {
+		return &ValidationError{"Int8SparseVector", -1, fmt.Sprintf("scale %v is not finite", q.scale)}
+	}
+	return nil
+}
+
+// Validate checks that the indices and values are the same length, the
+// indices are strictly increasing and the values are finite.
+func (q *Float16SparseVector) Validate() error {
+	if err := validateIndices("Float16SparseVector", q.indices, len(q.values)); err != nil {
+		return err
+	}
+	for i := range q.values {
+		if err := validateValue("Float16SparseVector", i, q.at(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks that the indices and values are the same length, the
+// indices are strictly increasing and the values are finite.
+func (q *BFloat16SparseVector) Validate() error {
+	if err := validateIndices("BFloat16SparseVector", q.indices, len(q.values)); err != nil {
+		return err
+	}
+	for i := range q.values {
+		if err := validateValue("BFloat16SparseVector", i, q.at(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	_ validator = (*SparseVectorUint32)(nil)
+	_ validator = (*GenSparseVector)(nil)
+	_ validator = (*MapSparseVector)(nil)
+	_ validator = (*CompressedSparseVector)(nil)
+	_ validator = (*BitmapVector)(nil)
+	_ validator = (*Int8SparseVector)(nil)
+	_ validator = (*Float16SparseVector)(nil)
+	_ validator = (*BFloat16SparseVector)(nil)
+)
@@ -0,0 +1,27 @@
+//go:build sparsevectordebug
+
+package sparsevector
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDebugChecks(t *testing.T) {
+	tests := []func(){
+		func() { NewSparseVectorUint32([]uint32{1, 1}, []Value{1, 2}) },
+		func() { NewSparseVectorUint32([]uint32{1, 2}, []Value{1, 2}).MapIndices(map[uint32]uint32{1: 2, 2: 1}) },
+		func() { NewGenSparseVector(IntIndex{1, 2}, []Value{1, 2}).Mult(Value(math.Inf(1))) },
+		func() { NewBitmapVector([]uint32{1}).Mult(Value(math.Inf(1))) },
+	}
+	for i, test := range tests {
+		func() {
+			defer func() {
+				if _, ok := recover().(*ValidationError); !ok {
+					t.Errorf("Test %d. Expected a panic with a ValidationError", i)
+				}
+			}()
+			test()
+		}()
+	}
+}
@@ -0,0 +1,76 @@
+package sparsevector
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	inf := Value(math.Inf(1))
+	nan := Value(math.NaN())
+
+	tests := []struct {
+		v        validator
+		position int
+		reason   string
+	}{
+		{NewSparseVectorUint32([]uint32{3, 1, 2}, []Value{1, 2, 3}), 0, ""},
+		{&SparseVectorUint32{indices: []uint32{1, 2}, values: []Value{1}}, -1, "2 indices but 1 values"},
+		{&SparseVectorUint32{indices: []uint32{1, 3, 2}, values: []Value{1, 2, 3}}, 2, "index 2 follows 3"},
+		{&SparseVectorUint32{indices: []uint32{1, 1}, values: []Value{1, 2}}, 1, "index 1 follows 1"},
+		{&SparseVectorUint32{indices: []uint32{1, 2}, values: []Value{1, nan}}, 1, "value NaN is not finite"},
+
+		{NewGenSparseVector(StringIndex{"b", "a"}, []Value{1, 2}), 0, ""},
+		{&GenSparseVector{index: StringIndex{"a"}, values: []Value{1, 2}}, -1, "1 indices but 2 values"},
+		{&GenSparseVector{index: StringIndex{"b", "a"}, values: []Value{1, 2}}, 1, "index a follows b"},
+		{&GenSparseVector{index: IntIndex{1, 2}, values: []Value{inf, 2}}, 0, "value +Inf is not finite"},
+		{&GenSparseVector{}, 0, ""},
+		{&GenSparseVector{values: []Value{1}}, -1, "no index but 1 values"},
+
+		{NewMapSparseVector([]uint32{1, 2}, []Value{1, 2}), 0, ""},
+		{&MapSparseVector{values: map[uint32]Value{7: inf}}, -1, "value +Inf at index 7 is not finite"},
+
+		{NewCompressedSparseVector(NewSparseVectorUint32([]uint32{1, 2}, []Value{1, 2})), 0, ""},
+		{&CompressedSparseVector{length: 1}, -1, "length 1 but 0 values"},
+
+		{NewBitmapVector([]uint32{1, 70000, 2}), 0, ""},
+		{&BitmapVector{keys: []uint16{1}, containers: []bitmapContainer{arrayContainer{2, 2}}, card: 2, weight: 1}, 1, "index 65538 follows 65538"},
+		{&BitmapVector{keys: []uint16{2, 1}, containers: []bitmapContainer{arrayContainer{1}, arrayContainer{1}}, card: 2, weight: 1}, 1, "container key 1 follows 2"},
+		{&BitmapVector{keys: []uint16{1}, containers: []bitmapContainer{arrayContainer{1}}, card: 2, weight: 1}, -1, "cardinality is 2, but containers hold 1"},
+		{&BitmapVector{keys: []uint16{1}, containers: []bitmapContainer{runContainer{{1, 2}, {4, 1}}}, card: 5, weight: 1}, 0, "run 1 overlaps or touches the previous run"},
+
+		{NewInt8SparseVector(NewSparseVectorUint32([]uint32{1}, []Value{1})), 0, ""},
+		{&Int8SparseVector{indices: []uint32{1}, values: []int8{1}, scale: nan}, -1, "scale NaN is not finite"},
+		{&Float16SparseVector{indices: []uint32{1, 2}, values: []uint16{0x3c00, 0x7c00}}, 1, "value +Inf is not finite"},
+		{&BFloat16SparseVector{indices: []uint32{2, 1}, values: []uint16{1, 1}}, 1, "index 1 follows 2"},
+	}
+
+	for i, test := range tests {
+		err := test.v.Validate()
+		if test.reason == "" {
+			if err != nil {
+				t.Errorf("Test %d. Unexpected error %v", i, err)
+			}
+			continue
+		}
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("Test %d. Expected a ValidationError, have %v", i, err)
+			continue
+		}
+		if verr.Position != test.position || verr.Reason != test.reason {
+			t.Errorf("Test %d. Error at %d with %q, expected at %d with %q", i, verr.Position, verr.Reason, test.position, test.reason)
+		}
+	}
+
+	// After MapIndices the order can change
+	sv := NewSparseVectorUint32([]uint32{1, 2}, []Value{1, 2})
+	func() {
+		defer func() { recover() }()
+		sv.MapIndices(map[uint32]uint32{1: 5, 2: 4})
+	}()
+	if err := sv.Validate(); err == nil || err.Error() != "sparsevector: invalid SparseVectorUint32 at position 1: index 4 follows 5" {
+		t.Errorf("Error after MapIndices not as expected. Have %v", err)
+	}
+}
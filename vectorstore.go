@@ -0,0 +1,220 @@
+package sparsevector
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"unsafe"
+)
+
+// A vector store file holds a collection of SparseVectorUint32 laid out so
+// that it can be memory mapped and used without decoding. All numbers are
+// little-endian. The file is
+//
+//	magic     [4]byte "SVST"
+//	version   uint32
+//	count     uint64  number of vectors
+//	total     uint64  total number of entries in all vectors
+//	offsets   [count+1]uint64  vector i has entries offsets[i] to offsets[i+1]
+//	indices   [total]uint32
+//	values    [total]float32
+//
+// Every array is naturally aligned relative to the start of the file.
+const (
+	vectorStoreMagic      = "SVST"
+	vectorStoreVersion    = 1
+	vectorStoreHeaderSize = 24
+)
+
+// VectorStore is a read-only collection of SparseVectorUint32 backed by a
+// memory mapped file. Vectors are views directly onto the file, so opening a
+// store and reading vectors from it costs almost nothing regardless of the
+// size of the store.
+type VectorStore struct {
+	data    []byte
+	offsets []uint64
+	indices []uint32
+	values  []Value
+	close   func() error
+}
+
+// WriteVectorStore writes vectors in vector store format. Each vector must
+// be valid, with strictly increasing indices, as they are for vectors built
+// with NewSparseVectorUint32. Nothing is written if a vector is invalid.
+func WriteVectorStore(w io.Writer, vectors []*SparseVectorUint32) error {
+	var total uint64
+	for i, v := range vectors {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("sparsevector: can't write vector %d. %w", i, err)
+		}
+		total += uint64(len(v.indices))
+	}
+
+	bw := bufio.NewWriter(w)
+	var buf [vectorStoreHeaderSize]byte
+	copy(buf[:], vectorStoreMagic)
+	binary.LittleEndian.PutUint32(buf[4:], vectorStoreVersion)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(len(vectors)))
+	binary.LittleEndian.PutUint64(buf[16:], total)
+	bw.Write(buf[:])
+
+	var offset uint64
+	binary.LittleEndian.PutUint64(buf[:], offset)
+	bw.Write(buf[:8])
+	for _, v := range vectors {
+		offset += uint64(len(v.indices))
+		binary.LittleEndian.PutUint64(buf[:], offset)
+		bw.Write(buf[:8])
+	}
+	for _, v := range vectors {
+		for _, index := range v.indices {
+			binary.LittleEndian.PutUint32(buf[:], index)
+			bw.Write(buf[:4])
+		}
+	}
+	for _, v := range vectors {
+		for _, value := range v.values {
+			binary.LittleEndian.PutUint32(buf[:], math.Float32bits(float32(value)))
+			bw.Write(buf[:4])
+		}
+	}
+	// bufio.Writer remembers the first error, so we only need to check here
+	return bw.Flush()
+}
+
+// OpenVectorStore opens a file written by WriteVectorStore. Call Close when
+// you are done with the store. Vectors taken from the store must not be used
+// after it is closed.
+//
+// Opening checks the layout of the file, and that the first index of each
+// vector is below its last, without reading every index. Call Validate to
+// check every vector in full.
+func OpenVectorStore(filename string) (*VectorStore, error) {
+	data, closer, err := mapFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newVectorStore(data)
+	if err != nil {
+		closer()
+		return nil, err
+	}
+	s.close = closer
+	return s, nil
+}
+
+// newVectorStore checks the layout of data and builds a VectorStore over it
+func newVectorStore(data []byte) (*VectorStore, error) {
+	if len(data) < vectorStoreHeaderSize || string(data[:4]) != vectorStoreMagic {
+		return nil, errors.New("sparsevector: not a vector store file")
+	}
+	if version := binary.LittleEndian.Uint32(data[4:]); version != vectorStoreVersion {
+		return nil, fmt.Errorf("sparsevector: unsupported vector store version %d", version)
+	}
+	count := binary.LittleEndian.Uint64(data[8:])
+	total := binary.LittleEndian.Uint64(data[16:])
+
+	rest := uint64(len(data) - vectorStoreHeaderSize)
+	if count >= rest/8 || total > (rest-(count+1)*8)/8 {
+		return nil, errors.New("sparsevector: vector store file is truncated")
+	}
+	if rest != (count+1)*8+total*8 {
+		return nil, fmt.Errorf("sparsevector: vector store file is %d bytes, expected %d", len(data), vectorStoreHeaderSize+(count+1)*8+total*8)
+	}
+
+	offsetsStart := uint64(vectorStoreHeaderSize)
+	indicesStart := offsetsStart + (count+1)*8
+	valuesStart := indicesStart + total*4
+
+	s := &VectorStore{data: data}
+	if isLittleEndian() && uintptr(unsafe.Pointer(&data[0]))%8 == 0 {
+		s.offsets = unsafe.Slice((*uint64)(unsafe.Pointer(&data[offsetsStart])), count+1)
+		if total > 0 {
+			s.indices = unsafe.Slice((*uint32)(unsafe.Pointer(&data[indicesStart])), total)
+			s.values = unsafe.Slice((*Value)(unsafe.Pointer(&data[valuesStart])), total)
+		}
+	} else {
+		// We can't use the data in place, so decode a copy
+		s.offsets = make([]uint64, count+1)
+		for i := range s.offsets {
+			s.offsets[i] = binary.LittleEndian.Uint64(data[offsetsStart+uint64(i)*8:])
+		}
+		s.indices = make([]uint32, total)
+		s.values = make([]Value, total)
+		for i := range s.indices {
+			s.indices[i] = binary.LittleEndian.Uint32(data[indicesStart+uint64(i)*4:])
+			s.values[i] = Value(math.Float32frombits(binary.LittleEndian.Uint32(data[valuesStart+uint64(i)*4:])))
+		}
+	}
+
+	// Check the offsets so that Vector can't panic
+	if s.offsets[0] != 0 || s.offsets[count] != total {
+		return nil, errors.New("sparsevector: vector store offsets are corrupt")
+	}
+	for i := uint64(1); i <= count; i++ {
+		if s.offsets[i] < s.offsets[i-1] {
+			return nil, fmt.Errorf("sparsevector: vector store offset %d is corrupt", i)
+		}
+	}
+	// A cheap check that the indices are in order
+	for i := uint64(0); i < count; i++ {
+		if start, end := s.offsets[i], s.offsets[i+1]; end-start > 1 && s.indices[start] >= s.indices[end-1] {
+			return nil, fmt.Errorf("sparsevector: vector store vector %d has indices out of order", i)
+		}
+	}
+	return s, nil
+}
+
+// Len returns the number of vectors in the store
+func (s *VectorStore) Len() int { return len(s.offsets) - 1 }
+
+// Vector returns vector i from the store. The vector is a view onto the
+// store, and must not be modified: Mult, AddConst, SubConst, IterUpdate and
+// MapIndices will fault. Add and Sub return new vectors and are fine, as are
+// Dot and Cos.
+func (s *VectorStore) Vector(i int) *SparseVectorUint32 {
+	start, end := s.offsets[i], s.offsets[i+1]
+	return &SparseVectorUint32{
+		indices: s.indices[start:end:end],
+		values:  s.values[start:end:end],
+	}
+}
+
+// Validate checks that the indices of every vector in the store are strictly
+// increasing and the values are finite. It reads the whole store, so unlike
+// OpenVectorStore its cost grows with the size of the store. The position
+// reported is the position of the entry in the store.
+func (s *VectorStore) Validate() error {
+	for i := 0; i < s.Len(); i++ {
+		start, end := s.offsets[i], s.offsets[i+1]
+		for j := start; j < end; j++ {
+			if j > start && s.indices[j] <= s.indices[j-1] {
+				return &ValidationError{"VectorStore", int(j), fmt.Sprintf("index %d follows %d in vector %d", s.indices[j], s.indices[j-1], i)}
+			}
+			if err := validateValue("VectorStore", int(j), s.values[j]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close releases the store. Vectors taken from the store must not be used
+// after it is closed.
+func (s *VectorStore) Close() error {
+	s.offsets, s.indices, s.values, s.data = nil, nil, nil, nil
+	if s.close == nil {
+		return nil
+	}
+	close := s.close
+	s.close = nil
+	return close()
+}
+
+func isLittleEndian() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package sparsevector
+
+import (
+	"os"
+)
+
+// mapFile reads the file into memory, as we don't have mmap on this platform
+func mapFile(filename string) ([]byte, func() error, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,148 @@
+package sparsevector
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTestVectorStore(t testing.TB, vectors []*SparseVectorUint32) string {
+	filename := filepath.Join(t.TempDir(), "vectors.svst")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := WriteVectorStore(f, vectors); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestVectorStore(t *testing.T) {
+	vectors := []*SparseVectorUint32{
+		NewSparseVectorUint32([]uint32{1, 3, 4}, []Value{4, 5, 6}),
+		NewSparseVectorUint32([]uint32{}, []Value{}),
+		NewSparseVectorUint32([]uint32{2, 3}, []Value{-1, 0.5}),
+		genRandomSparseVector(1000),
+		genRandomSparseVector(1000),
+	}
+
+	s, err := OpenVectorStore(writeTestVectorStore(t, vectors))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if s.Len() != len(vectors) {
+		t.Fatalf("store has %d vectors, expected %d", s.Len(), len(vectors))
+	}
+
+	for i, exp := range vectors {
+		v := s.Vector(i)
+		if len(v.indices) != len(exp.indices) || (len(v.indices) > 0 && !reflect.DeepEqual(exp.indices, v.indices)) {
+			t.Errorf("Vector %d. Indices not as expected. Have %v", i, v.indices)
+		}
+		if len(v.values) != len(exp.values) || (len(v.values) > 0 && !reflect.DeepEqual(exp.values, v.values)) {
+			t.Errorf("Vector %d. Values not as expected. Have %v", i, v.values)
+		}
+	}
+
+	if dot, exp := s.Vector(3).Dot(s.Vector(4)), vectors[3].Dot(vectors[4]); dot != exp {
+		t.Errorf("Dot not as expected. Have %f, expected %f", dot, exp)
+	}
+	if cos, exp := s.Vector(0).Cos(vectors[2]), vectors[0].Cos(vectors[2]); cos != exp {
+		t.Errorf("Cos not as expected. Have %f, expected %f", cos, exp)
+	}
+
+	// Add must not write into the store
+	sum := s.Vector(0).Add(s.Vector(2)).(*SparseVectorUint32)
+	if !reflect.DeepEqual(NewSparseVectorUint32([]uint32{1, 2, 3, 4}, []Value{4, -1, 5.5, 6}), sum) {
+		t.Errorf("Sum not as expected. Have %v", sum)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVectorStoreCorrupt(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteVectorStore(&buf, []*SparseVectorUint32{
+		NewSparseVectorUint32([]uint32{1, 3, 4}, []Value{4, 5, 6}),
+		NewSparseVectorUint32([]uint32{2, 3}, []Value{-1, 0.5}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	good := buf.Bytes()
+
+	if s, err := newVectorStore(append([]byte(nil), good...)); err != nil {
+		t.Fatalf("good data rejected. %v", err)
+	} else if err := s.Validate(); err != nil {
+		t.Fatalf("good data not valid. %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		mangle func(data []byte) []byte
+	}{
+		{name: "empty", mangle: func(data []byte) []byte { return data[:0] }},
+		{name: "magic", mangle: func(data []byte) []byte { data[0] = 'X'; return data }},
+		{name: "version", mangle: func(data []byte) []byte { data[4] = 2; return data }},
+		{name: "truncated", mangle: func(data []byte) []byte { return data[:len(data)-4] }},
+		{name: "extended", mangle: func(data []byte) []byte { return append(data, 0, 0, 0, 0) }},
+		{name: "count", mangle: func(data []byte) []byte { data[15] = 0xff; return data }},
+		{name: "total", mangle: func(data []byte) []byte { data[16] = 4; return data }},
+		{name: "offset order", mangle: func(data []byte) []byte { data[32] = 6; return data }},
+		{name: "first offset", mangle: func(data []byte) []byte { data[24] = 1; return data }},
+		{name: "index order", mangle: func(data []byte) []byte { data[48] = 9; return data }},
+	}
+
+	for _, test := range tests {
+		data := test.mangle(append([]byte(nil), good...))
+		if _, err := newVectorStore(data); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+
+	// Opening only checks the first and last index of each vector
+	data := append([]byte(nil), good...)
+	data[52] = 0
+	s, err := newVectorStore(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Validate(); err == nil || err.Error() != "sparsevector: invalid VectorStore at position 1: index 0 follows 1 in vector 0" {
+		t.Errorf("Validate error not as expected. Have %v", err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(filename, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenVectorStore(filename); err == nil {
+		t.Errorf("expected an error opening an empty file")
+	}
+}
+
+func TestWriteVectorStoreInvalid(t *testing.T) {
+	tests := []*SparseVectorUint32{
+		{indices: []uint32{1, 2}, values: []Value{1}},
+		{indices: []uint32{3, 1}, values: []Value{1, 2}},
+		{indices: []uint32{1, 1}, values: []Value{1, 2}},
+	}
+	for i, test := range tests {
+		var buf bytes.Buffer
+		if err := WriteVectorStore(&buf, []*SparseVectorUint32{NewSparseVectorUint32([]uint32{1}, []Value{1}), test}); err == nil {
+			t.Errorf("Test %d. Expected an error", i)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("Test %d. Wrote %d bytes", i, buf.Len())
+		}
+	}
+}
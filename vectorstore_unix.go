@@ -0,0 +1,36 @@
+//go:build unix
+
+package sparsevector
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps a file read-only into memory
+func mapFile(filename string) ([]byte, func() error, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := fi.Size()
+	if size == 0 {
+		// Can't map an empty file, and an empty file isn't a valid store
+		return []byte{}, func() error { return nil }, nil
+	}
+	if int64(int(size)) != size {
+		return nil, nil, syscall.EFBIG
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, &os.PathError{Op: "mmap", Path: filename, Err: err}
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
@@ -0,0 +1,243 @@
+package sparsevector
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Vocabulary assigns dense uint32 ids to keys, so that vectors indexed by
+// strings or other keys can be converted to the much faster
+// SparseVectorUint32. Ids are assigned in the order keys are first seen, and
+// don't change unless the vocabulary is pruned.
+//
+// Keys can be of any comparable type, but for a vocabulary to be serialized
+// all its keys must be strings, all ints, or all uint32s.
+//
+// The vocabulary also counts how often each key is seen, so that rare and
+// very common keys can be pruned.
+type Vocabulary struct {
+	ids    map[interface{}]uint32
+	keys   []interface{}
+	counts []int
+}
+
+// NewVocabulary creates an empty Vocabulary
+func NewVocabulary() *Vocabulary {
+	return &Vocabulary{
+		ids: make(map[interface{}]uint32),
+	}
+}
+
+// Len returns the number of keys in the vocabulary
+func (v *Vocabulary) Len() int { return len(v.keys) }
+
+// Add adds a key to the vocabulary if it is not already present, and counts
+// it. It returns the id for the key.
+func (v *Vocabulary) Add(key interface{}) uint32 {
+	id, ok := v.ids[key]
+	if !ok {
+		id = uint32(len(v.keys))
+		v.ids[key] = id
+		v.keys = append(v.keys, key)
+		v.counts = append(v.counts, 0)
+	}
+	v.counts[id]++
+	return id
+}
+
+// ID returns the id for a key, and whether the key is present.
+func (v *Vocabulary) ID(key interface{}) (uint32, bool) {
+	id, ok := v.ids[key]
+	return id, ok
+}
+
+// Key returns the key for an id
+func (v *Vocabulary) Key(id uint32) interface{} { return v.keys[id] }
+
+// Count returns how many times the key with this id has been counted
+func (v *Vocabulary) Count(id uint32) int { return v.counts[id] }
+
+// AddVector adds each index of a vector to the vocabulary. Each index is
+// counted once, so after adding a collection of vectors the counts are
+// document frequencies.
+func (v *Vocabulary) AddVector(gsv *GenSparseVector) {
+	for i := range gsv.values {
+		v.Add(gsv.index.GetAtLocation(i))
+	}
+}
+
+// Encode converts a GenSparseVector to a SparseVectorUint32 using the ids in
+// the vocabulary. Indices that aren't in the vocabulary are dropped.
+func (v *Vocabulary) Encode(gsv *GenSparseVector) *SparseVectorUint32 {
+	indices := make([]uint32, 0, len(gsv.values))
+	values := make([]Value, 0, len(gsv.values))
+	for i, value := range gsv.values {
+		if id, ok := v.ids[gsv.index.GetAtLocation(i)]; ok {
+			indices = append(indices, id)
+			values = append(values, value)
+		}
+	}
+	return NewSparseVectorUint32(indices, values)
+}
+
+// Decode converts a SparseVectorUint32 back to a GenSparseVector. index is an
+// index of the type to use, such as StringIndex(nil). The keys must be of the
+// right type for the index. Ids that aren't in the vocabulary are dropped.
+func (v *Vocabulary) Decode(sv *SparseVectorUint32, index VectorIndex) *GenSparseVector {
+	out := index.New(len(sv.indices))
+	values := make([]Value, 0, len(sv.indices))
+	for i, id := range sv.indices {
+		if int64(id) < int64(len(v.keys)) {
+			out = out.Append(v.keys[id])
+			values = append(values, sv.values[i])
+		}
+	}
+	return NewGenSparseVector(out, values)
+}
+
+// Prune removes keys counted fewer than minCount times or more than maxCount
+// times. If maxCount is zero or less there is no maximum. Remaining keys are
+// given new ids, keeping their order.
+//
+// Prune returns a map from old ids to new ids. Ids of removed keys are not in
+// the map, so vectors that contain them should be encoded again rather than
+// passed to MapIndices.
+func (v *Vocabulary) Prune(minCount, maxCount int) map[uint32]uint32 {
+	remap := make(map[uint32]uint32, len(v.keys))
+	var keys []interface{}
+	var counts []int
+	for id, key := range v.keys {
+		count := v.counts[id]
+		if count < minCount || (maxCount > 0 && count > maxCount) {
+			delete(v.ids, key)
+			continue
+		}
+		newID := uint32(len(keys))
+		remap[uint32(id)] = newID
+		v.ids[key] = newID
+		keys = append(keys, key)
+		counts = append(counts, count)
+	}
+	v.keys = keys
+	v.counts = counts
+	return remap
+}
+
+// MarshalBinary encodes the vocabulary. The keys must all be strings, all
+// ints or all uint32s.
+//
+// The encoding is a version byte and a key kind byte, then the number of
+// keys as a uvarint, then the keys in id order, then the counts as uvarints.
+func (v *Vocabulary) MarshalBinary() ([]byte, error) {
+	kind := binaryKindString
+	if len(v.keys) > 0 {
+		switch v.keys[0].(type) {
+		case string:
+		case int:
+			kind = binaryKindInt
+		case uint32:
+			kind = binaryKindUint32
+		default:
+			return nil, fmt.Errorf("sparsevector: cannot encode vocabulary key of type %T", v.keys[0])
+		}
+	}
+
+	buf := appendBinaryHeader(nil, kind, len(v.keys))
+	for _, key := range v.keys {
+		var ok bool
+		switch kind {
+		case binaryKindString:
+			var s string
+			if s, ok = key.(string); ok {
+				buf = binary.AppendUvarint(buf, uint64(len(s)))
+				buf = append(buf, s...)
+			}
+		case binaryKindInt:
+			var i int
+			if i, ok = key.(int); ok {
+				buf = binary.AppendVarint(buf, int64(i))
+			}
+		case binaryKindUint32:
+			var u uint32
+			if u, ok = key.(uint32); ok {
+				buf = binary.AppendUvarint(buf, uint64(u))
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("sparsevector: vocabulary has keys of types %T and %T", v.keys[0], key)
+		}
+	}
+	for _, count := range v.counts {
+		buf = binary.AppendUvarint(buf, uint64(count))
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a vocabulary encoded with MarshalBinary. It
+// replaces the contents of v.
+func (v *Vocabulary) UnmarshalBinary(data []byte) error {
+	kind, data, err := readBinaryHeader(data)
+	if err != nil {
+		return err
+	}
+	l, n := binary.Uvarint(data)
+	// Each key and each count takes at least one byte
+	if n <= 0 || l > uint64(len(data)-n)/2 {
+		return errors.New("sparsevector: bad vocabulary length")
+	}
+	data = data[n:]
+
+	nv := NewVocabulary()
+	if l > 0 {
+		nv.keys = make([]interface{}, 0, l)
+		nv.counts = make([]int, l)
+	}
+	for i := 0; i < int(l); i++ {
+		var key interface{}
+		switch kind {
+		case binaryKindString:
+			sl, n := binary.Uvarint(data)
+			if n <= 0 || sl > uint64(len(data)-n) {
+				return fmt.Errorf("sparsevector: bad vocabulary key %d", i)
+			}
+			key = string(data[n : n+int(sl)])
+			data = data[n+int(sl):]
+		case binaryKindInt:
+			k, n := binary.Varint(data)
+			if n <= 0 || int64(int(k)) != k {
+				return fmt.Errorf("sparsevector: bad vocabulary key %d", i)
+			}
+			key = int(k)
+			data = data[n:]
+		case binaryKindUint32:
+			k, n := binary.Uvarint(data)
+			if n <= 0 || k > math.MaxUint32 {
+				return fmt.Errorf("sparsevector: bad vocabulary key %d", i)
+			}
+			key = uint32(k)
+			data = data[n:]
+		default:
+			return ErrUnsupportedIndex
+		}
+		if _, ok := nv.ids[key]; ok {
+			return fmt.Errorf("sparsevector: vocabulary key %v repeated", key)
+		}
+		nv.ids[key] = uint32(i)
+		nv.keys = append(nv.keys, key)
+	}
+	for i := range nv.counts {
+		count, n := binary.Uvarint(data)
+		if n <= 0 || count > math.MaxInt {
+			return fmt.Errorf("sparsevector: bad vocabulary count %d", i)
+		}
+		nv.counts[i] = int(count)
+		data = data[n:]
+	}
+	if len(data) != 0 {
+		return fmt.Errorf("sparsevector: %d unexpected bytes after vocabulary", len(data))
+	}
+	*v = *nv
+	return nil
+}
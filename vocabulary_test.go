@@ -0,0 +1,136 @@
+package sparsevector
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestVocabulary(t *testing.T) {
+	v := NewVocabulary()
+	docs := []*GenSparseVector{
+		NewGenSparseVector(StringIndex{"the", "cat", "sat"}, []Value{2, 1, 1}),
+		NewGenSparseVector(StringIndex{"the", "dog", "sat"}, []Value{1, 1, 1}),
+		NewGenSparseVector(StringIndex{"the", "end"}, []Value{1, 3}),
+	}
+	for _, doc := range docs {
+		v.AddVector(doc)
+	}
+
+	if v.Len() != 5 {
+		t.Fatalf("vocabulary has %d keys", v.Len())
+	}
+	// Vectors are sorted, so ids are assigned in key order within each
+	// vector
+	exp := []string{"cat", "sat", "the", "dog", "end"}
+	for id, key := range exp {
+		if v.Key(uint32(id)) != key {
+			t.Errorf("key %d is %v, expected %s", id, v.Key(uint32(id)), key)
+		}
+		if got, ok := v.ID(key); !ok || got != uint32(id) {
+			t.Errorf("id for %s is %d, %t", key, got, ok)
+		}
+	}
+	if v.Count(2) != 3 || v.Count(0) != 1 {
+		t.Errorf("counts not as expected")
+	}
+	if _, ok := v.ID("missing"); ok {
+		t.Errorf("missing key found")
+	}
+
+	sv := v.Encode(NewGenSparseVector(StringIndex{"the", "dog", "missing"}, []Value{1, 2, 3}))
+	if !reflect.DeepEqual(NewSparseVectorUint32([]uint32{2, 3}, []Value{1, 2}), sv) {
+		t.Errorf("encoded vector not as expected. Have %v", sv)
+	}
+
+	gsv := v.Decode(NewSparseVectorUint32([]uint32{0, 3, 9}, []Value{1, 2, 3}), StringIndex(nil))
+	if !reflect.DeepEqual(NewGenSparseVector(StringIndex{"cat", "dog"}, []Value{1, 2}), gsv) {
+		t.Errorf("decoded vector not as expected. Have %v", gsv)
+	}
+
+	// Dot products agree either way
+	if dp, exp := v.Encode(docs[0]).Dot(v.Encode(docs[1])), docs[0].Dot(docs[1]); dp != exp {
+		t.Errorf("Dot of encoded vectors is %f, expected %f", dp, exp)
+	}
+}
+
+func TestVocabularyPrune(t *testing.T) {
+	v := NewVocabulary()
+	for _, key := range []string{"a", "b", "b", "c", "c", "c", "d", "d"} {
+		v.Add(key)
+	}
+
+	remap := v.Prune(2, 2)
+	if !reflect.DeepEqual(map[uint32]uint32{1: 0, 3: 1}, remap) {
+		t.Errorf("remap not as expected. Have %v", remap)
+	}
+	if v.Len() != 2 || v.Key(0) != "b" || v.Key(1) != "d" || v.Count(1) != 2 {
+		t.Errorf("pruned vocabulary not as expected. Have %v", v.keys)
+	}
+	if id, ok := v.ID("d"); !ok || id != 1 {
+		t.Errorf("id for d is %d, %t", id, ok)
+	}
+	if _, ok := v.ID("c"); ok {
+		t.Errorf("c should have been pruned")
+	}
+
+	v.Add("e")
+	v.Prune(1, 0)
+	if v.Len() != 3 {
+		t.Errorf("vocabulary has %d keys", v.Len())
+	}
+}
+
+func TestVocabularyBinary(t *testing.T) {
+	tests := [][]interface{}{
+		{},
+		{"x", "", "héllo"},
+		{-5, 7, math.MaxInt},
+		{uint32(4), uint32(4294967295)},
+	}
+
+	for i, test := range tests {
+		v := NewVocabulary()
+		for j, key := range test {
+			for k := 0; k <= j; k++ {
+				v.Add(key)
+			}
+		}
+
+		data, err := v.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Test %d. %v", i, err)
+		}
+		v2 := NewVocabulary()
+		if err := v2.UnmarshalBinary(data); err != nil {
+			t.Fatalf("Test %d. %v", i, err)
+		}
+		if !reflect.DeepEqual(v, v2) {
+			t.Errorf("Test %d. Round trip not as expected. Have %v", i, v2)
+		}
+	}
+
+	v := NewVocabulary()
+	v.Add("a")
+	v.Add(1)
+	if _, err := v.MarshalBinary(); err == nil {
+		t.Errorf("expected an error for mixed keys")
+	}
+	v = NewVocabulary()
+	v.Add(1.5)
+	if _, err := v.MarshalBinary(); err == nil {
+		t.Errorf("expected an error for float keys")
+	}
+
+	for i, data := range [][]byte{
+		{},
+		{1, 3, 2, 1, 'a', 1, 'a', 1, 1},
+		{1, 3, 1, 1, 'a'},
+		{1, 3, 1, 1, 'a', 1, 0},
+		{1, 9, 1, 1, 1},
+	} {
+		if err := v.UnmarshalBinary(data); err == nil {
+			t.Errorf("Test %d. Expected an error", i)
+		}
+	}
+}